@@ -0,0 +1,143 @@
+// Command apikeys creates, lists and revokes the API keys accepted by the
+// server when AUTH_ENABLED is set.
+//
+//	apikeys create -name dashboard -scopes read-events,read-analytics
+//	apikeys create -name acme -role viewer -company <company_id>
+//	apikeys list
+//	apikeys revoke <id>
+//
+// Keys are stored hashed in the server's key file, set by API_KEYS_PATH or
+// auth.keysPath in CONFIG_FILE, or in the file given with -file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/config"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikeys: %v\n", err)
+		os.Exit(1)
+	}
+	file := flags.String("file", cfg.APIKeysPath, "API key file")
+
+	switch command {
+	case "create":
+		name := flags.String("name", "", "name describing who uses the key")
+		role := flags.String("role", auth.RoleAnalyst, "role: admin, analyst or viewer")
+		scopes := flags.String("scopes", "", "comma-separated scopes: read-events, read-analytics, ingest, admin (default: the role's scopes)")
+		company := flags.String("company", "", "company ID the key is restricted to")
+		flags.Parse(args)
+		err = create(*file, *name, *role, *scopes, *company)
+	case "list":
+		flags.Parse(args)
+		err = list(*file)
+	case "revoke":
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			usage()
+		}
+		err = revoke(*file, flags.Arg(0))
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikeys %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+// create adds a key and prints it; the full key cannot be shown again
+func create(file, name, role, scopes, company string) error {
+	if name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	store, err := auth.OpenKeyStore(file)
+	if err != nil {
+		return err
+	}
+
+	var scopeList []string
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopeList = append(scopeList, scope)
+		}
+	}
+
+	token, key, err := store.Create(name, role, scopeList, company)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s key %s (%s) with scopes %s\n", key.Role, key.ID, key.Name, strings.Join(key.Scopes, ","))
+	if key.CompanyID != "" {
+		fmt.Printf("Restricted to company %s\n", key.CompanyID)
+	}
+	fmt.Printf("\n  %s\n\n", token)
+	fmt.Println("Store it now; only its hash is kept.")
+	return nil
+}
+
+// list prints every key with its scopes and status
+func list(file string) error {
+	store, err := auth.OpenKeyStore(file)
+	if err != nil {
+		return err
+	}
+	keys, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tSCOPES\tCOMPANY\tCREATED\tSTATUS")
+	for _, key := range keys {
+		status := "active"
+		if key.Revoked() {
+			status = "revoked " + key.RevokedAt.Format("2006-01-02")
+		}
+		company := key.CompanyID
+		if company == "" {
+			company = "all"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.AccessRole(), strings.Join(key.Scopes, ","), company, key.CreatedAt.Format("2006-01-02"), status)
+	}
+	return w.Flush()
+}
+
+// revoke revokes the key with the given ID
+func revoke(file, id string) error {
+	store, err := auth.OpenKeyStore(file)
+	if err != nil {
+		return err
+	}
+	if err := store.Revoke(id); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked key %s\n", id)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikeys create -name NAME [-role ROLE] [-scopes SCOPES] [-company ID] [-file PATH]")
+	fmt.Fprintln(os.Stderr, "       apikeys list [-file PATH]")
+	fmt.Fprintln(os.Stderr, "       apikeys revoke [-file PATH] ID")
+	os.Exit(2)
+}
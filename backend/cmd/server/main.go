@@ -1,35 +1,143 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"analytics-dashboard/pkg/api"
+	"analytics-dashboard/pkg/audit"
+	"analytics-dashboard/pkg/auth"
 	"analytics-dashboard/pkg/config"
 	"analytics-dashboard/pkg/services"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	configPath := flag.String("config", "", "YAML config file (default: $CONFIG_FILE)")
+	flag.Parse()
+
+	// Load configuration from the file, if any, and the environment
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Write logs at the configured level and format from here on
+	slog.SetDefault(newLogger(cfg.LoggingConfig))
+	if cfg.File != "" {
+		slog.Info("Loaded configuration", "file", cfg.File)
+	}
 
 	// Initialize data service
 	dataService, err := services.NewDataService(cfg.DataPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize data service: %v", err)
+		fatalf("Failed to initialize data service: %v", err)
+	}
+
+	// Configure query time zones
+	if err := dataService.ConfigureTimezones(cfg.DefaultTimezone, cfg.CompanyTimezones); err != nil {
+		fatalf("Invalid timezone configuration: %v", err)
+	}
+
+	// Configure the first day of weekly buckets and cohorts
+	weekStart, err := services.ParseWeekday(cfg.WeekStart)
+	if err != nil {
+		fatalf("Invalid WEEK_START: %v", err)
+	}
+	dataService.ConfigureWeekStart(weekStart)
+
+	// Map endpoint templates to product features
+	if err := dataService.ConfigureFeatures(cfg.FeatureMap); err != nil {
+		fatalf("Invalid FEATURE_MAP: %v", err)
+	}
+
+	// Resolve user emails to stable identities
+	if err := dataService.ConfigureIdentities(cfg.IdentityAliasesPath, cfg.IdentityMergeRules); err != nil {
+		fatalf("Invalid identity configuration: %v", err)
+	}
+
+	// Load event catalog annotations
+	if err := dataService.ConfigureCatalog(cfg.CatalogPath); err != nil {
+		fatalf("Failed to load event catalog: %v", err)
+	}
+
+	// Load saved segments
+	if err := dataService.ConfigureSegments(cfg.SegmentsPath); err != nil {
+		fatalf("Failed to load segments: %v", err)
 	}
 
 	// Load CSV data
 	if err := dataService.LoadData(); err != nil {
-		log.Fatalf("Failed to load data: %v", err)
+		fatalf("Failed to load data: %v", err)
 	}
 
-	log.Printf("Successfully loaded %d events from CSV", dataService.GetTotalEvents())
+	slog.Info("Successfully loaded events from CSV", "events", dataService.GetTotalEvents())
 
 	// Initialize and start server
 	server := api.NewServer(cfg, dataService)
 
-	log.Printf("Starting server on port %s", cfg.Port)
-	if err := server.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Require API keys when authentication is enabled
+	if cfg.AuthEnabled {
+		keys, err := auth.OpenKeyStore(cfg.APIKeysPath)
+		if err != nil {
+			fatalf("Failed to load API keys: %v", err)
+		}
+		server.EnableAuth(keys)
+		slog.Info("API key authentication enabled", "keys", cfg.APIKeysPath)
+		if cfg.PseudonymSecret == "" {
+			slog.Warn("PSEUDONYM_SECRET is not set; user IDs shown to viewers change on every restart")
+		}
+	}
+
+	// Record API access when an audit log is configured
+	if cfg.AuditLogPath != "" {
+		auditLog, err := audit.Open(cfg.AuditLogPath, int64(cfg.AuditLogMaxBytes), cfg.AuditLogMaxFiles)
+		if err != nil {
+			fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+		server.EnableAudit(auditLog)
+		slog.Info("Audit logging enabled", "log", cfg.AuditLogPath)
+	}
+
+	// Shut down gracefully on SIGINT or SIGTERM; a second signal exits
+	// immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	slog.Info("Starting server", "port", cfg.Port)
+	if err := server.Run(ctx); err != nil {
+		fatalf("Server stopped with error: %v", err)
 	}
+	slog.Info("Server stopped")
+}
+
+// newLogger builds the default logger from the logging settings. Messages
+// from the standard log package are written through it at info level.
+func newLogger(cfg config.LoggingConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	if cfg.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, options))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, options))
+}
+
+// fatalf logs an error and exits. Unlike log.Fatalf its message is kept
+// when the log level is above info.
+func fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
 }
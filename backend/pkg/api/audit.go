@@ -0,0 +1,163 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"analytics-dashboard/pkg/audit"
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on the number of entries returned by the audit log endpoint
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditRequests records every request it wraps to the audit log, once the
+// handlers have run, so the caller identity set by requireScope and the
+// response size are known. It does nothing while audit logging is disabled.
+func (s *Server) auditRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.audit == nil {
+			c.Next()
+			return
+		}
+
+		started := time.Now()
+		c.Next()
+
+		entry := audit.Entry{
+			Time:      started.UTC(),
+			Role:      c.GetString(auth.RoleContextKey),
+			Tenant:    c.GetString(auth.TenantContextKey),
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Query:     normalizedQuery(c),
+			Status:    c.Writer.Status(),
+			Bytes:     c.Writer.Size(),
+			LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+		}
+		if key, ok := c.Get(apiKeyContextKey); ok {
+			entry.KeyID = key.(auth.Key).ID
+			entry.KeyName = key.(auth.Key).Name
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+
+		if err := s.audit.Record(entry); err != nil {
+			slog.Error("Failed to record audit entry", "error", err)
+		}
+	}
+}
+
+// normalizedQuery returns the request's query parameters normalized the
+// same way as cache keys, joining repeated parameters with commas
+func normalizedQuery(c *gin.Context) map[string]string {
+	query := c.Request.URL.Query()
+	if len(query) == 0 {
+		return nil
+	}
+
+	normalized := make(map[string]string, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			if value = normalizeQueryValue(value); value == "" {
+				continue
+			}
+			if normalized[name] != "" {
+				value = normalized[name] + "," + value
+			}
+			normalized[name] = value
+		}
+	}
+	return normalized
+}
+
+// getAuditLog handles GET /api/v1/admin/audit. Entries can be filtered by
+// user (key ID or name), route (template or path) and a since/until window
+// given as RFC 3339 times or YYYY-MM-DD dates, and are returned newest
+// first.
+func (s *Server) getAuditLog(c *gin.Context) {
+	if s.audit == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "audit logging is not enabled",
+			},
+		})
+		return
+	}
+
+	filter := audit.Filter{
+		User:  c.Query("user"),
+		Route: c.Query("route"),
+		Limit: defaultAuditLimit,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(c.Query("since")); err != nil {
+		writeInvalidAuditTime(c, "since", err)
+		return
+	}
+	if filter.Until, err = parseAuditTime(c.Query("until")); err != nil {
+		writeInvalidAuditTime(c, "until", err)
+		return
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INTERNAL_ERROR",
+				Message: "failed to read the audit log",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  entries,
+		"total": len(entries),
+	})
+}
+
+// parseAuditTime parses an RFC 3339 time or a UTC date; empty is zero
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// writeInvalidAuditTime writes a 400 response for an unparseable time
+func writeInvalidAuditTime(c *gin.Context, param string, err error) {
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error: models.ErrorDetails{
+			Code:    "INVALID_REQUEST",
+			Message: param + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			Details: err.Error(),
+		},
+	})
+}
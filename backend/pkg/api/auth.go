@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyContextKey is the gin context key holding the authenticated auth.Key
+const apiKeyContextKey = "apiKey"
+
+// requireScope rejects requests without an active API key granting scope.
+// Keys are read from "Authorization: Bearer <key>" or the X-API-Key header.
+// The key's role and, for tenant keys, its company ID are set on the
+// request; handlers pass the tenant down to every DataService query and mask
+// personal data for viewers. It lets every request through while
+// authentication is disabled.
+func (s *Server) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.keys == nil {
+			c.Next()
+			return
+		}
+
+		key, err := s.keys.Authenticate(requestAPIKey(c))
+		if errors.Is(err, auth.ErrInvalidKey) {
+			c.Header("WWW-Authenticate", `Bearer realm="analytics-dashboard"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: models.ErrorDetails{
+					Code:    "UNAUTHORIZED",
+					Message: "a valid API key is required",
+				},
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: models.ErrorDetails{
+					Code:    "INTERNAL_ERROR",
+					Message: "failed to check the API key",
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+
+		// Identify the caller before the scope check so denied requests
+		// are audited with their key
+		c.Set(apiKeyContextKey, key)
+		c.Set(auth.RoleContextKey, key.AccessRole())
+		if key.CompanyID != "" {
+			c.Set(auth.TenantContextKey, key.CompanyID)
+		}
+
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Error: models.ErrorDetails{
+					Code:    "FORBIDDEN",
+					Message: "this API key lacks the " + scope + " scope",
+				},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requestAPIKey returns the API key sent with a request, if any
+func requestAPIKey(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, token, found := strings.Cut(header, " "); found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(c.GetHeader("X-API-Key"))
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"analytics-dashboard/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedResponse is a successful response body stored in the query cache
+type cachedResponse struct {
+	contentType string
+	etag        string
+	body        []byte
+}
+
+// bufferedWriter holds back a handler's response so it can be cached and
+// tagged with an ETag before anything is sent to the client
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// responseCache serves repeated GET requests from the query cache. Entries
+// are keyed on the route and normalized query parameters and are tagged
+// with the dataset generation, so any data change invalidates them.
+func (s *Server) responseCache() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		generation := s.dataService.Generation()
+		key := cacheKey(c)
+
+		if value, ok := s.cache.Get(key, generation); ok {
+			cached := value.(*cachedResponse)
+			c.Header("X-Cache", "HIT")
+			writeCachedResponse(c, cached)
+			c.Abort()
+			return
+		}
+
+		original := c.Writer
+		writer := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		if writer.status != http.StatusOK {
+			c.Writer.WriteHeader(writer.status)
+			c.Writer.Write(writer.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(writer.body.Bytes())
+		cached := &cachedResponse{
+			contentType: original.Header().Get("Content-Type"),
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+			body:        writer.body.Bytes(),
+		}
+		s.cache.Add(key, cached, generation)
+
+		c.Header("X-Cache", "MISS")
+		writeCachedResponse(c, cached)
+	}
+}
+
+// writeCachedResponse writes a cached body, or 304 Not Modified when the
+// client already holds the same ETag
+func writeCachedResponse(c *gin.Context, cached *cachedResponse) {
+	c.Header("ETag", cached.etag)
+	c.Header("Cache-Control", "no-cache")
+
+	if etagMatches(c.GetHeader("If-None-Match"), cached.etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Data(http.StatusOK, cached.contentType, cached.body)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey builds a cache key from the request path and its query
+// parameters. Parameters are sorted, values trimmed, comma-separated lists
+// sorted and empty values dropped, so equivalent requests share an entry.
+func cacheKey(c *gin.Context) string {
+	query := c.Request.URL.Query()
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Tenants and roles see different data for the same URL, so they never
+	// share entries
+	var key strings.Builder
+	for _, name := range []string{auth.TenantContextKey, auth.RoleContextKey} {
+		if value := c.GetString(name); value != "" {
+			key.WriteString(name)
+			key.WriteString("=")
+			key.WriteString(value)
+			key.WriteString("|")
+		}
+	}
+	key.WriteString(c.Request.URL.Path)
+	for _, name := range names {
+		for _, value := range query[name] {
+			value = normalizeQueryValue(value)
+			if value == "" {
+				continue
+			}
+			key.WriteString("|")
+			key.WriteString(name)
+			key.WriteString("=")
+			key.WriteString(value)
+		}
+	}
+
+	return key.String()
+}
+
+// normalizeQueryValue trims a value and sorts it if it is a comma-separated list
+func normalizeQueryValue(value string) string {
+	if !strings.Contains(value, ",") {
+		return strings.TrimSpace(value)
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"analytics-dashboard/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getConfig handles GET /api/v1/admin/config. It returns the effective
+// configuration after file and environment overrides, in the layout of the
+// config file, with secrets redacted.
+func (s *Server) getConfig(c *gin.Context) {
+	settings, err := s.config.Redacted()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INTERNAL_ERROR",
+				Message: "failed to encode the configuration",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+		"file": s.config.File,
+	})
+}
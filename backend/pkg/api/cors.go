@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"analytics-dashboard/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsPolicy is the configured cross-origin resource sharing policy
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          map[string]bool // Exact origins, lower case
+	wildcardSuffixes []string        // From patterns like https://*.example.com, lower case
+	methods          string
+	headers          string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string // Seconds; empty to leave the browser default
+}
+
+// newCORSPolicy builds the policy from the CORS settings in cfg. An origin
+// of "*" allows every origin, and a "*." after the scheme allows any
+// subdomain, e.g. https://*.example.com.
+func newCORSPolicy(cfg *config.Config) corsPolicy {
+	policy := corsPolicy{
+		origins:          make(map[string]bool),
+		methods:          strings.Join(cfg.CORSAllowedMethods, ", "),
+		headers:          strings.Join(cfg.CORSAllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(cfg.CORSExposedHeaders, ", "),
+		allowCredentials: cfg.CORSAllowCredentials,
+	}
+	if seconds := int(cfg.CORSMaxAge.Seconds()); seconds > 0 {
+		policy.maxAge = strconv.Itoa(seconds)
+	}
+
+	for _, origin := range cfg.CORSAllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(origin, "/"))
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			policy.wildcardSuffixes = append(policy.wildcardSuffixes, scheme+"://|"+host)
+		default:
+			policy.origins[origin] = true
+		}
+	}
+	return policy
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or "" if the origin is not allowed. A wildcard policy answers "*"
+// unless credentials are allowed, since browsers reject "*" on credentialed
+// requests and echoing any origin would let every site read responses with
+// the user's credentials.
+func (p corsPolicy) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	if p.anyOrigin && !p.allowCredentials {
+		return "*"
+	}
+
+	normalized := strings.ToLower(origin)
+	if p.origins[normalized] {
+		return origin
+	}
+	for _, pattern := range p.wildcardSuffixes {
+		scheme, suffix, _ := strings.Cut(pattern, "|")
+		if strings.HasPrefix(normalized, scheme) && strings.HasSuffix(normalized, suffix) && len(normalized) > len(scheme)+len(suffix) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors applies the CORS policy. Responses that depend on the request origin
+// carry "Vary: Origin" so shared caches keep them apart, and preflight
+// requests are answered directly with 204.
+func (s *Server) cors() gin.HandlerFunc {
+	policy := newCORSPolicy(s.config)
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !policy.anyOrigin || policy.allowCredentials {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if allowed := policy.allowedOrigin(origin); allowed != "" {
+			c.Header("Access-Control-Allow-Origin", allowed)
+			if policy.allowCredentials && allowed != "*" {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				c.Header("Access-Control-Allow-Methods", policy.methods)
+				c.Header("Access-Control-Allow-Headers", policy.headers)
+				if policy.maxAge != "" {
+					c.Header("Access-Control-Max-Age", policy.maxAge)
+				}
+			} else if policy.exposedHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", policy.exposedHeaders)
+			}
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestDateParametersAreValidated(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	cases := []struct {
+		path string
+		code string
+	}{
+		{"/api/v1/trends?timeframe=hourly&startDate=foo&endDate=2025-01-10", "INVALID_DATE"},
+		{"/api/v1/trends?timeframe=daily&startDate=2025-01-10&endDate=2025-01-01", "INVALID_DATE"},
+		{"/api/v1/trends?timeframe=hourly&startDate=0001-01-01&endDate=2025-01-10", "RANGE_TOO_LARGE"},
+		{"/api/v1/trends/multi-company?timeframe=hourly&startDate=2000-01-01&endDate=2025-01-10", "RANGE_TOO_LARGE"},
+		{"/api/v1/metrics?startDate=2025-13-01", "INVALID_DATE"},
+		{"/api/v1/analytics/active-users?endDate=yesterday", "INVALID_DATE"},
+		{"/api/v1/companies/cmp-acme?timeframe=hourly&startDate=1990-01-01&endDate=2025-01-10", "RANGE_TOO_LARGE"},
+	}
+	for _, tc := range cases {
+		w := serve(server, http.MethodGet, tc.path, tokens["all"], "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", tc.path, w.Code, w.Body)
+			continue
+		}
+		var response models.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if response.Error.Code != tc.code {
+			t.Errorf("%s: code %s, want %s", tc.path, response.Error.Code, tc.code)
+		}
+	}
+
+	// A year of hourly buckets is within the limit
+	w := serve(server, http.MethodGet, "/api/v1/trends?timeframe=hourly&startDate=2025-01-01&endDate=2025-12-31", tokens["all"], "")
+	if w.Code != http.StatusOK {
+		t.Errorf("year of hourly buckets: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/privacy"
+	"analytics-dashboard/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CatalogHandler handles event catalog requests
+type CatalogHandler struct {
+	dataService *services.DataService
+}
+
+// NewCatalogHandler creates a new catalog handler
+func NewCatalogHandler(dataService *services.DataService) *CatalogHandler {
+	return &CatalogHandler{
+		dataService: dataService,
+	}
+}
+
+// GetEventCatalog handles GET /api/v1/event-catalog
+func (h *CatalogHandler) GetEventCatalog(c *gin.Context) {
+	response := h.dataService.GetEventCatalog(tenantOptions(c)...)
+	if maskPII(c) {
+		response = privacy.MaskCatalog(response)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AnnotateEntry handles PUT /api/v1/event-catalog
+func (h *CatalogHandler) AnnotateEntry(c *gin.Context) {
+	var req models.CatalogAnnotation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_REQUEST",
+				Message: "request body must be a JSON object with type, attribute, description and owner",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	annotation, err := h.dataService.AnnotateCatalogEntry(req)
+	if errors.Is(err, services.ErrUnknownCatalogEntry) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "no events have this type and attribute",
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INTERNAL_ERROR",
+				Message: "failed to save the event catalog",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"analytics-dashboard/pkg/auth"
 	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/privacy"
 	"analytics-dashboard/pkg/services"
 
 	"github.com/gin-gonic/gin"
@@ -14,12 +17,14 @@ import (
 // EventHandler handles event-related API requests
 type EventHandler struct {
 	dataService *services.DataService
+	pseudonyms  *privacy.Pseudonyms // User IDs shown to viewers
 }
 
 // NewEventHandler creates a new event handler
-func NewEventHandler(dataService *services.DataService) *EventHandler {
+func NewEventHandler(dataService *services.DataService, pseudonyms *privacy.Pseudonyms) *EventHandler {
 	return &EventHandler{
 		dataService: dataService,
+		pseudonyms:  pseudonyms,
 	}
 }
 
@@ -76,8 +81,26 @@ func (h *EventHandler) GetEvents(c *gin.Context) {
 		searchReq.Filters.Companies = []string{company}
 	}
 
+	opts, ok := h.queryOptions(c, searchReq.Filters.Companies)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
+	// Viewers cannot search emails, or match counts would confirm them
+	if maskPII(c) {
+		opts = append(opts, services.WithoutPersonalSearch())
+	}
+
 	// Get events and metrics
-	response := h.dataService.SearchEvents(searchReq)
+	response := h.dataService.SearchEvents(searchReq, opts...)
+
+	if maskPII(c) {
+		response.Data = privacy.MaskEvents(response.Data)
+	}
 
 	// Combine response without metrics (metrics will be fetched separately)
 	c.JSON(http.StatusOK, gin.H{
@@ -113,11 +136,38 @@ func (h *EventHandler) GetTimeSeriesData(c *gin.Context) {
 	}
 
 	// Validate timeframe
-	if timeframe != "daily" && timeframe != "weekly" && timeframe != "monthly" {
+	if !services.IsValidTimeframe(timeframe) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetails{
 				Code:    "INVALID_TIMEFRAME",
-				Message: "timeframe must be one of: daily, weekly, monthly",
+				Message: "timeframe must be one of: hourly, daily, weekly, monthly, quarterly",
+			},
+		})
+		return
+	}
+	if !validDateRange(c, timeframe) {
+		return
+	}
+
+	// Validate optional breakdown
+	splitBy := c.Query("splitBy")
+	if splitBy != "" && !services.IsValidSplitBy(splitBy) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_SPLIT_BY",
+				Message: "splitBy must be one of: company, user, endpoint, type, attribute",
+			},
+		})
+		return
+	}
+
+	// Validate optional measure
+	measure := c.DefaultQuery("measure", services.MeasureCount)
+	if !services.IsValidMeasure(measure) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_MEASURE",
+				Message: "measure must be one of: count, sum, avg, min, max, p50, p90, p99",
 			},
 		})
 		return
@@ -134,7 +184,35 @@ func (h *EventHandler) GetTimeSeriesData(c *gin.Context) {
 		eventTypes = strings.Split(eventTypesStr, ",")
 	}
 
-	response := h.dataService.GetTimeSeriesData(timeframe, startDate, endDate, companies, eventTypes)
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if measure != services.MeasureCount {
+		response := h.dataService.GetTimeSeriesMeasure(timeframe, startDate, endDate, companies, eventTypes, splitBy, measure, top, opts...)
+		if maskPII(c) {
+			response = privacy.MaskTimeSeries(response, h.pseudonyms, h.dataService.UserID)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if splitBy != "" {
+		response := h.dataService.GetTimeSeriesBreakdown(timeframe, startDate, endDate, companies, eventTypes, splitBy, top, opts...)
+		if maskPII(c) {
+			response = privacy.MaskTimeSeries(response, h.pseudonyms, h.dataService.UserID)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := h.dataService.GetTimeSeriesData(timeframe, startDate, endDate, companies, eventTypes, opts...)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -162,6 +240,20 @@ func (h *EventHandler) GetMultiCompanyTrends(c *gin.Context) {
 		timeframe = "daily"
 	}
 
+	// Validate timeframe
+	if !services.IsValidTimeframe(timeframe) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_TIMEFRAME",
+				Message: "timeframe must be one of: hourly, daily, weekly, monthly, quarterly",
+			},
+		})
+		return
+	}
+	if !validDateRange(c, timeframe) {
+		return
+	}
+
 	// Parse comma-separated companies
 	var companies []string
 	if companiesStr != "" {
@@ -182,13 +274,24 @@ func (h *EventHandler) GetMultiCompanyTrends(c *gin.Context) {
 		}
 	}
 
+	// Resolve the zone before defaulting to all companies so a single
+	// requested company can supply its configured zone
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
 	// If no companies specified, get data for all companies
 	if len(companies) == 0 {
-		allCompanies := h.dataService.GetAllCompanyNames()
+		allCompanies := h.dataService.GetAllCompanyNames(tenantOptions(c)...)
 		companies = allCompanies
 	}
 
-	response := h.dataService.GetMultiCompanyTimeSeriesData(timeframe, startDate, endDate, companies, eventTypes)
+	response := h.dataService.GetMultiCompanyTimeSeriesData(timeframe, startDate, endDate, companies, eventTypes, opts...)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -219,7 +322,23 @@ func (h *EventHandler) GetMetrics(c *gin.Context) {
 		}
 	}
 
-	response := h.dataService.GetMetrics(startDate, endDate, companies, eventTypes)
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
+	response := h.dataService.GetMetrics(startDate, endDate, companies, eventTypes, opts...)
+
+	// Approximate mode adds a sketch-based distinct user estimate
+	if isApprox(c) {
+		uniqueUsers := h.dataService.ApproxUniqueUsersCount(startDate, endDate, companies, eventTypes, opts...)
+		response.UniqueUsers = &uniqueUsers
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -242,7 +361,16 @@ func (h *EventHandler) GetFilteredMetrics(c *gin.Context) {
 		// Backward compatibility: support single company parameter
 		companiesList = []string{company}
 	}
-	metrics := h.dataService.GetMetrics(startDate, endDate, companiesList, []string{})
+	opts, ok := h.queryOptions(c, companiesList)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
+	metrics := h.dataService.GetMetrics(startDate, endDate, companiesList, []string{}, opts...)
 
 	// Calculate additional metrics
 	topEventType := "N/A"
@@ -257,40 +385,57 @@ func (h *EventHandler) GetFilteredMetrics(c *gin.Context) {
 		avgEventsPerCompany = metrics.TotalEvents / metrics.ActiveCompanies
 	}
 
-	// Calculate unique users from filtered events
-	uniqueUsers := h.dataService.GetUniqueUsersCount(startDate, endDate, companiesList)
-
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"totalEvents":         metrics.TotalEvents,
 		"uniqueCompanies":     metrics.ActiveCompanies,
-		"uniqueUsers":         uniqueUsers,
 		"topEventType":        topEventType,
 		"topEventCount":       topEventCount,
 		"avgEventsPerCompany": avgEventsPerCompany,
-	})
+	}
+	if metrics.ValueStats != nil {
+		response["valueStats"] = metrics.ValueStats
+	}
+
+	// Calculate unique users from filtered events
+	if isApprox(c) {
+		uniqueUsers := h.dataService.ApproxUniqueUsersCount(startDate, endDate, companiesList, nil, opts...)
+		response["uniqueUsers"] = uniqueUsers.Estimate
+		response["uniqueUsersErrorBound"] = uniqueUsers.ErrorBound
+		response["approximate"] = true
+	} else {
+		response["uniqueUsers"] = h.dataService.GetUniqueUsersCount(startDate, endDate, companiesList, opts...)
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetCompanies handles GET /api/v1/companies
 func (h *EventHandler) GetCompanies(c *gin.Context) {
-	response := h.dataService.GetCompanies()
+	response := h.dataService.GetCompanies(tenantOptions(c)...)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetStatus handles GET /api/v1/status
+func (h *EventHandler) GetStatus(c *gin.Context) {
+	response := h.dataService.GetDataStatus(tenantOptions(c)...)
 	c.JSON(http.StatusOK, response)
 }
 
 // GetEventTypes handles GET /api/v1/event-types
 func (h *EventHandler) GetEventTypes(c *gin.Context) {
-	response := h.dataService.GetEventDistribution()
+	response := h.dataService.GetEventDistribution(tenantOptions(c)...)
 	c.JSON(http.StatusOK, response)
 }
 
 // GetTopActiveCompanies handles GET /api/v1/analytics/companies
 func (h *EventHandler) GetTopActiveCompanies(c *gin.Context) {
-	response := h.dataService.GetTopActiveCompanies()
+	response := h.dataService.GetTopActiveCompanies(tenantOptions(c)...)
 	c.JSON(http.StatusOK, response)
 }
 
 // GetEventDistribution handles GET /api/v1/analytics/event-distribution
 func (h *EventHandler) GetEventDistribution(c *gin.Context) {
-	response := h.dataService.GetEventDistribution()
+	response := h.dataService.GetEventDistribution(tenantOptions(c)...)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -311,7 +456,12 @@ func (h *EventHandler) GetTopEventsByVolume(c *gin.Context) {
 		}
 	}
 
-	response := h.dataService.GetTopEventsByVolume(startDate, endDate, companies, limit)
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	response := h.dataService.GetTopEventsByVolume(startDate, endDate, companies, limit, opts...)
 	c.JSON(http.StatusOK, gin.H{
 		"data":  response,
 		"total": len(response),
@@ -335,11 +485,27 @@ func (h *EventHandler) GetMostActiveUsers(c *gin.Context) {
 		}
 	}
 
-	response := h.dataService.GetMostActiveUsers(startDate, endDate, companies, limit)
-	c.JSON(http.StatusOK, gin.H{
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	response := h.dataService.GetMostActiveUsers(startDate, endDate, companies, limit, opts...)
+	if maskPII(c) {
+		response = privacy.MaskUserActivities(response, h.pseudonyms)
+	}
+	result := gin.H{
 		"data":  response,
 		"total": len(response),
-	})
+	}
+
+	// Approximate mode adds a sketch-based estimate of all distinct users
+	if isApprox(c) {
+		result["uniqueUsers"] = h.dataService.ApproxUniqueUsersCount(startDate, endDate, companies, nil, opts...)
+		result["approximate"] = true
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
 // GetTopEndpointsByUsage handles GET /api/v1/analytics/top-endpoints
@@ -359,7 +525,23 @@ func (h *EventHandler) GetTopEndpointsByUsage(c *gin.Context) {
 		}
 	}
 
-	response := h.dataService.GetTopEndpointsByUsage(startDate, endDate, companies, limit)
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	// Approximate mode groups by endpoint template and estimates distinct users
+	if isApprox(c) {
+		response := h.dataService.ApproxTopEndpointsByUsage(startDate, endDate, companies, limit, opts...)
+		c.JSON(http.StatusOK, gin.H{
+			"data":        response,
+			"total":       len(response),
+			"approximate": true,
+		})
+		return
+	}
+
+	response := h.dataService.GetTopEndpointsByUsage(startDate, endDate, companies, limit, opts...)
 	c.JSON(http.StatusOK, gin.H{
 		"data":  response,
 		"total": len(response),
@@ -383,7 +565,23 @@ func (h *EventHandler) GetTopActiveCompaniesWithFiltering(c *gin.Context) {
 		}
 	}
 
-	response := h.dataService.GetTopActiveCompaniesWithFiltering(startDate, endDate, companies, limit)
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	// Approximate mode estimates distinct users from rollup sketches
+	if isApprox(c) {
+		response := h.dataService.ApproxTopActiveCompanies(startDate, endDate, companies, limit, opts...)
+		c.JSON(http.StatusOK, gin.H{
+			"data":        response,
+			"total":       len(response),
+			"approximate": true,
+		})
+		return
+	}
+
+	response := h.dataService.GetTopActiveCompaniesWithFiltering(startDate, endDate, companies, limit, opts...)
 	c.JSON(http.StatusOK, gin.H{
 		"data":  response,
 		"total": len(response),
@@ -418,8 +616,21 @@ func (h *EventHandler) GetRetentionAnalytics(c *gin.Context) {
 		MinCohortSize: minCohortSize,
 	}
 
+	var companies []string
+	if company != "" {
+		companies = []string{company}
+	}
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+	opts, ok = h.segmentOption(c, opts)
+	if !ok {
+		return
+	}
+
 	// Get retention analytics
-	response, err := h.dataService.GetRetentionAnalytics(req)
+	response, err := h.dataService.GetRetentionAnalytics(req, opts...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
@@ -433,3 +644,293 @@ func (h *EventHandler) GetRetentionAnalytics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GetUserPaths handles GET /api/v1/analytics/paths
+func (h *EventHandler) GetUserPaths(c *gin.Context) {
+	startEndpoint := c.Query("startEndpoint")
+	endEndpoint := c.Query("endEndpoint")
+	companiesStr := c.Query("companies")
+	steps, _ := strconv.Atoi(c.DefaultQuery("steps", "3"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	// Exactly one endpoint anchors the paths
+	if (startEndpoint == "") == (endEndpoint == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "MISSING_PARAMETERS",
+				Message: "exactly one of startEndpoint or endEndpoint is required",
+			},
+		})
+		return
+	}
+
+	// Keep paths short enough to render
+	if steps < 1 {
+		steps = 1
+	}
+	if steps > 10 {
+		steps = 10
+	}
+
+	// Parse comma-separated companies
+	var companies []string
+	if companiesStr != "" {
+		companies = strings.Split(companiesStr, ",")
+		// Trim whitespace from each company name
+		for i, companyName := range companies {
+			companies[i] = strings.TrimSpace(companyName)
+		}
+	}
+
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	req := models.PathRequest{
+		StartEndpoint: startEndpoint,
+		EndEndpoint:   endEndpoint,
+		Steps:         steps,
+		Limit:         limit,
+		Companies:     companies,
+		StartDate:     c.Query("startDate"),
+		EndDate:       c.Query("endDate"),
+	}
+
+	response := h.dataService.GetUserPaths(req, opts...)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetFeatureAdoption handles GET /api/v1/analytics/adoption
+func (h *EventHandler) GetFeatureAdoption(c *gin.Context) {
+	startDate := c.Query("startDate")
+	endDate := c.Query("endDate")
+	companiesStr := c.Query("companies")
+
+	// Parse comma-separated companies
+	var companies []string
+	if companiesStr != "" {
+		companies = strings.Split(companiesStr, ",")
+		// Trim whitespace from each company name
+		for i, companyName := range companies {
+			companies[i] = strings.TrimSpace(companyName)
+		}
+	}
+
+	opts, ok := h.queryOptions(c, companies)
+	if !ok {
+		return
+	}
+
+	response := h.dataService.GetFeatureAdoption(startDate, endDate, companies, opts...)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCompanyDetail handles GET /api/v1/companies/:id
+func (h *EventHandler) GetCompanyDetail(c *gin.Context) {
+	companyID := c.Param("id")
+	timeframe := c.DefaultQuery("timeframe", services.TimeframeDaily)
+
+	// Validate timeframe
+	if !services.IsValidTimeframe(timeframe) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_TIMEFRAME",
+				Message: "timeframe must be one of: hourly, daily, weekly, monthly, quarterly",
+			},
+		})
+		return
+	}
+	if !validDateRange(c, timeframe) {
+		return
+	}
+
+	// A configured zone for this company applies unless tz is given
+	opts, ok := h.queryOptions(c, []string{h.dataService.GetCompanyName(companyID)})
+	if !ok {
+		return
+	}
+
+	response, found := h.dataService.GetCompanyDetail(companyID, timeframe, c.Query("startDate"), c.Query("endDate"), opts...)
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "no company with this id",
+			},
+		})
+		return
+	}
+
+	if maskPII(c) {
+		response = privacy.MaskCompanyDetail(response, h.pseudonyms, h.dataService.UserID)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUserProfile handles GET /api/v1/users/:email
+func (h *EventHandler) GetUserProfile(c *gin.Context) {
+	// A profile is looked up by email, so answering viewers would confirm
+	// which guessed emails belong to users
+	if maskPII(c) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "FORBIDDEN",
+				Message: "user profiles are not available to viewers",
+			},
+		})
+		return
+	}
+
+	user := c.Param("email")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	opts, ok := h.queryOptions(c, nil)
+	if !ok {
+		return
+	}
+
+	pagination := models.PaginationRequest{
+		Page:     page,
+		PageSize: pageSize,
+	}
+	response, found := h.dataService.GetUserProfile(user, c.Query("startDate"), c.Query("endDate"), pagination, opts...)
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "no events found for this user",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// isApprox reports whether the request asked for approximate distinct counts
+func isApprox(c *gin.Context) bool {
+	approx, _ := strconv.ParseBool(c.Query("approx"))
+	return approx
+}
+
+// queryOptions builds the options shared by date-based endpoints: the tz
+// query parameter (an IANA zone name), or the configured zone for the
+// requested company, the optional weekStart parameter and valueStats=true.
+// It also checks the startDate and endDate parameters. It writes a 400
+// response and returns false when a value is invalid.
+func (h *EventHandler) queryOptions(c *gin.Context, companies []string) ([]services.QueryOption, bool) {
+	if !validDateRange(c, "") {
+		return nil, false
+	}
+
+	// A tenant's own company supplies the default zone
+	tenant := c.GetString(auth.TenantContextKey)
+	if len(companies) == 0 && tenant != "" {
+		companies = []string{h.dataService.GetCompanyName(tenant)}
+	}
+
+	loc, err := h.dataService.ResolveLocation(c.Query("tz"), companies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_TIMEZONE",
+				Message: "tz must be an IANA time zone name such as America/New_York",
+				Details: err.Error(),
+			},
+		})
+		return nil, false
+	}
+	opts := append(tenantOptions(c), services.InLocation(loc))
+
+	if weekStart := c.Query("weekStart"); weekStart != "" {
+		day, err := services.ParseWeekday(weekStart)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetails{
+					Code:    "INVALID_WEEK_START",
+					Message: "weekStart must be a day of the week such as monday or sunday",
+					Details: err.Error(),
+				},
+			})
+			return nil, false
+		}
+		opts = append(opts, services.WithWeekStart(day))
+	}
+
+	if valueStats, _ := strconv.ParseBool(c.Query("valueStats")); valueStats {
+		opts = append(opts, services.WithValueStats())
+	}
+
+	return opts, true
+}
+
+// validDateRange checks the startDate and endDate query parameters that
+// are present, and for a timeframe that the range stays within its bucket
+// limit. It writes a 400 response and returns false when they are invalid.
+func validDateRange(c *gin.Context, timeframe string) bool {
+	startDate, endDate := c.Query("startDate"), c.Query("endDate")
+	switch {
+	case startDate == "" && endDate == "":
+		return true
+	case startDate == "":
+		startDate = endDate
+	case endDate == "":
+		endDate = startDate
+	}
+
+	err := services.ValidateDateRange(timeframe, startDate, endDate)
+	if err == nil {
+		return true
+	}
+
+	code, message := "INVALID_DATE", "startDate and endDate must be YYYY-MM-DD dates, with endDate not before startDate"
+	if errors.Is(err, services.ErrRangeTooLarge) {
+		code, message = "RANGE_TOO_LARGE", "the date range spans too many buckets for this timeframe; narrow it or use a coarser timeframe"
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error: models.ErrorDetails{
+			Code:    code,
+			Message: message,
+			Details: err.Error(),
+		},
+	})
+	return false
+}
+
+// segmentOption appends the option for the segment query parameter, if
+// present. It writes a 404 response and returns false when no segment has
+// that ID.
+func (h *EventHandler) segmentOption(c *gin.Context, opts []services.QueryOption) ([]services.QueryOption, bool) {
+	id := c.Query("segment")
+	if id == "" {
+		return opts, true
+	}
+
+	if !h.dataService.SegmentExists(id) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "segment not found",
+			},
+		})
+		return nil, false
+	}
+	return append(opts, services.InSegment(id)), true
+}
+
+// maskPII reports whether personal data must be masked for the request's
+// role
+func maskPII(c *gin.Context) bool {
+	return c.GetString(auth.RoleContextKey) == auth.RoleViewer
+}
+
+// tenantOptions returns the option scoping queries to the company of a
+// tenant API key, or none for unrestricted requests
+func tenantOptions(c *gin.Context) []services.QueryOption {
+	if tenant := c.GetString(auth.TenantContextKey); tenant != "" {
+		return []services.QueryOption{services.ForTenant(tenant)}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestHandler handles event ingestion requests
+type IngestHandler struct {
+	buffer *services.IngestBuffer
+}
+
+// NewIngestHandler creates a new ingest handler
+func NewIngestHandler(buffer *services.IngestBuffer) *IngestHandler {
+	return &IngestHandler{
+		buffer: buffer,
+	}
+}
+
+// IngestEvents handles POST /api/v1/events/ingest
+func (h *IngestHandler) IngestEvents(c *gin.Context) {
+	var req models.IngestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_REQUEST",
+				Message: "request body must be a JSON object with an events array",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	// Tenant keys may only submit events for their own company
+	if tenant := c.GetString(auth.TenantContextKey); tenant != "" {
+		for _, event := range req.Events {
+			if event.CompanyID != tenant {
+				c.JSON(http.StatusForbidden, models.ErrorResponse{
+					Error: models.ErrorDetails{
+						Code:    "FORBIDDEN",
+						Message: "this API key may only ingest events for company " + tenant,
+						Details: "event " + event.ID + " has company_id " + event.CompanyID,
+					},
+				})
+				return
+			}
+		}
+	}
+
+	pending, err := h.buffer.Add(req.Events)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "VALIDATION_ERROR",
+				Message: "one or more events are invalid",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.IngestResponse{
+		Accepted: len(req.Events),
+		Pending:  pending,
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/privacy"
+	"analytics-dashboard/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SegmentHandler handles saved segment requests
+type SegmentHandler struct {
+	dataService *services.DataService
+}
+
+// NewSegmentHandler creates a new segment handler
+func NewSegmentHandler(dataService *services.DataService) *SegmentHandler {
+	return &SegmentHandler{
+		dataService: dataService,
+	}
+}
+
+// ListSegments handles GET /api/v1/segments
+func (h *SegmentHandler) ListSegments(c *gin.Context) {
+	response := h.dataService.ListSegments(tenantOptions(c)...)
+	if maskPII(c) {
+		response = privacy.MaskSegments(response)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetSegment handles GET /api/v1/segments/:id
+func (h *SegmentHandler) GetSegment(c *gin.Context) {
+	segment, exists := h.dataService.GetSegment(c.Param("id"), tenantOptions(c)...)
+	if !exists {
+		writeSegmentError(c, services.ErrSegmentNotFound)
+		return
+	}
+	if maskPII(c) {
+		segment = privacy.MaskSegment(segment)
+	}
+	c.JSON(http.StatusOK, segment)
+}
+
+// CreateSegment handles POST /api/v1/segments
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var req models.Segment
+	if !bindSegment(c, &req) {
+		return
+	}
+
+	segment, err := h.dataService.CreateSegment(req)
+	if err != nil {
+		writeSegmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, segment)
+}
+
+// UpdateSegment handles PUT /api/v1/segments/:id
+func (h *SegmentHandler) UpdateSegment(c *gin.Context) {
+	var req models.Segment
+	if !bindSegment(c, &req) {
+		return
+	}
+
+	segment, err := h.dataService.UpdateSegment(c.Param("id"), req)
+	if err != nil {
+		writeSegmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, segment)
+}
+
+// DeleteSegment handles DELETE /api/v1/segments/:id
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	if err := h.dataService.DeleteSegment(c.Param("id")); err != nil {
+		writeSegmentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindSegment decodes a segment definition from the request body. It writes
+// a 400 response and returns false when the body is not valid JSON.
+func bindSegment(c *gin.Context, segment *models.Segment) bool {
+	if err := c.ShouldBindJSON(segment); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INVALID_REQUEST",
+				Message: "request body must be a JSON object with name, description and rules",
+				Details: err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// writeSegmentError maps segment service errors to responses
+func writeSegmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSegmentNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "NOT_FOUND",
+				Message: "segment not found",
+			},
+		})
+	case errors.Is(err, services.ErrInvalidSegment):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "VALIDATION_ERROR",
+				Message: "segment definition is invalid",
+				Details: err.Error(),
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "INTERNAL_ERROR",
+				Message: "failed to save segments",
+				Details: err.Error(),
+			},
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"analytics-dashboard/pkg/metrics"
+	"analytics-dashboard/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxErrorBody is how much of an error response is kept to read its code
+const maxErrorBody = 4096
+
+// serverMetrics are the metrics served at /metrics. Request metrics are
+// recorded as requests complete; the dataset, cache and ingestion metrics
+// are refreshed from their sources on every scrape.
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests *metrics.Vec
+	latency  *metrics.HistogramVec
+	errors   *metrics.Vec
+
+	events       *metrics.Vec
+	companies    *metrics.Vec
+	users        *metrics.Vec
+	eventTypes   *metrics.Vec
+	skippedRows  *metrics.Vec
+	loadDuration *metrics.Vec
+
+	cacheHits     *metrics.Vec
+	cacheMisses   *metrics.Vec
+	cacheHitRatio *metrics.Vec
+	cacheEntries  *metrics.Vec
+
+	ingestBatches  *metrics.Vec
+	ingestEvents   *metrics.Vec
+	ingestDuration *metrics.Vec
+	ingestQueued   *metrics.Vec
+}
+
+// newServerMetrics registers the server's metrics
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	return &serverMetrics{
+		registry: r,
+
+		requests: r.Counter("analytics_http_requests_total", "HTTP requests by method, route template and status.", "method", "route", "status"),
+		latency:  r.Histogram("analytics_http_request_duration_seconds", "HTTP request latency by method and route template.", metrics.DefaultBuckets, "method", "route"),
+		errors:   r.Counter("analytics_http_errors_total", "Error responses by API error code.", "code"),
+
+		events:       r.Gauge("analytics_events", "Events in the dataset."),
+		companies:    r.Gauge("analytics_companies", "Companies in the dataset."),
+		users:        r.Gauge("analytics_users", "Distinct users in the dataset after identity resolution."),
+		eventTypes:   r.Gauge("analytics_event_types", "Event types in the dataset."),
+		skippedRows:  r.Gauge("analytics_data_skipped_rows", "CSV rows skipped as unreadable by the last load."),
+		loadDuration: r.Gauge("analytics_data_load_duration_seconds", "Time the last dataset load took."),
+
+		cacheHits:     r.Counter("analytics_cache_hits_total", "Query cache lookups answered from the cache."),
+		cacheMisses:   r.Counter("analytics_cache_misses_total", "Query cache lookups that had to be computed."),
+		cacheHitRatio: r.Gauge("analytics_cache_hit_ratio", "Share of query cache lookups answered from the cache since startup."),
+		cacheEntries:  r.Gauge("analytics_cache_entries", "Responses held in the query cache."),
+
+		ingestBatches:  r.Counter("analytics_ingest_batches_total", "Ingestion batches applied to the dataset."),
+		ingestEvents:   r.Counter("analytics_ingested_events_total", "Events applied to the dataset by ingestion."),
+		ingestDuration: r.Counter("analytics_ingest_duration_seconds_total", "Time spent applying ingestion batches."),
+		ingestQueued:   r.Gauge("analytics_ingest_queued_events", "Events waiting in the ingestion buffer."),
+	}
+}
+
+// observeRequests counts every request by route and status, times it and
+// counts error responses by their error code. Requests that match no route
+// are recorded under the route "unmatched" to keep label values bounded.
+func (s *Server) observeRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.metrics == nil {
+			c.Next()
+			return
+		}
+
+		started := time.Now()
+		writer := &errorCodeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		s.metrics.requests.Inc(c.Request.Method, route, strconv.Itoa(writer.Status()))
+		s.metrics.latency.Observe(time.Since(started).Seconds(), c.Request.Method, route)
+		if code := writer.errorCode(); code != "" {
+			s.metrics.errors.Inc(code)
+		}
+	}
+}
+
+// getMetrics handles GET /metrics in the Prometheus text format
+func (s *Server) getMetrics(c *gin.Context) {
+	m := s.metrics
+
+	stats := s.dataService.Stats()
+	m.events.Set(float64(stats.Events))
+	m.companies.Set(float64(stats.Companies))
+	m.users.Set(float64(stats.Users))
+	m.eventTypes.Set(float64(stats.EventTypes))
+	m.skippedRows.Set(float64(stats.SkippedRows))
+	m.loadDuration.Set(stats.LoadDuration.Seconds())
+
+	hits, misses := s.cache.Stats()
+	m.cacheHits.Set(float64(hits))
+	m.cacheMisses.Set(float64(misses))
+	m.cacheHitRatio.Set(0)
+	if hits+misses > 0 {
+		m.cacheHitRatio.Set(float64(hits) / float64(hits+misses))
+	}
+	m.cacheEntries.Set(float64(s.cache.Len()))
+
+	m.ingestBatches.Set(float64(stats.Ingest.Batches))
+	m.ingestEvents.Set(float64(stats.Ingest.Events))
+	m.ingestDuration.Set(stats.Ingest.Duration.Seconds())
+	m.ingestQueued.Set(float64(s.ingestBuffer.Pending()))
+
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if err := m.registry.Write(c.Writer); err != nil {
+		slog.Error("Failed to write metrics", "error", err)
+	}
+}
+
+// errorCodeWriter keeps the start of error response bodies, so the error
+// code in a models.ErrorResponse can be counted
+type errorCodeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorCodeWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorCodeWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture keeps body bytes of responses with an error status
+func (w *errorCodeWriter) capture(data []byte) {
+	if w.Status() < http.StatusBadRequest || w.body.Len() >= maxErrorBody {
+		return
+	}
+	w.body.Write(data[:min(len(data), maxErrorBody-w.body.Len())])
+}
+
+// errorCode returns the code of an error response, or "" if the response
+// is not a models.ErrorResponse
+func (w *errorCodeWriter) errorCode() string {
+	if w.body.Len() == 0 {
+		return ""
+	}
+	var response models.ErrorResponse
+	if err := json.Unmarshal(w.body.Bytes(), &response); err != nil {
+		return ""
+	}
+	return response.Error.Code
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestViewersCannotLookUpUsersByEmail(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	for _, email := range []string{"acme-user@example.com", "nobody@example.com"} {
+		if w := serve(server, http.MethodGet, "/api/v1/users/"+email, tokens["viewer"], ""); w.Code != http.StatusForbidden {
+			t.Errorf("viewer lookup of %s: status %d, want 403", email, w.Code)
+		}
+	}
+	if w := serve(server, http.MethodGet, "/api/v1/users/acme-user@example.com", tokens["all"], ""); w.Code != http.StatusOK {
+		t.Errorf("analyst lookup: status %d, want 200", w.Code)
+	}
+}
+
+func TestViewersSeePseudonymousUserIDs(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	activeUsers := func(token string) map[string]string {
+		t.Helper()
+		w := serve(server, http.MethodGet, "/api/v1/analytics/active-users", token, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var response struct {
+			Data []models.UserActivity `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]string)
+		for _, user := range response.Data {
+			ids[user.User] = user.UserID
+		}
+		return ids
+	}
+
+	stable := activeUsers(tokens["all"])
+	masked := activeUsers(tokens["viewer"])
+	if len(masked) != len(stable) || len(masked) == 0 {
+		t.Fatalf("viewer saw %d users, analyst %d", len(masked), len(stable))
+	}
+
+	seen := make(map[string]bool)
+	for email, id := range stable {
+		pseudonym := masked[email[:1]+"***@example.com"]
+		if !strings.HasPrefix(pseudonym, "anon_") || pseudonym == id {
+			t.Errorf("viewer ID for %s = %q, want a pseudonym distinct from %q", email, pseudonym, id)
+		}
+		if seen[pseudonym] {
+			t.Errorf("pseudonym %q repeated", pseudonym)
+		}
+		seen[pseudonym] = true
+	}
+}
+
+func TestViewersCannotSearchEventsByEmail(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	totalItems := func(token, query string) int {
+		t.Helper()
+		w := serve(server, http.MethodGet, "/api/v1/events?query="+query, token, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var response struct {
+			Pagination struct {
+				TotalItems int `json:"totalItems"`
+			} `json:"pagination"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Pagination.TotalItems
+	}
+
+	if n := totalItems(tokens["all"], "acme-user@example.com"); n == 0 {
+		t.Fatal("analyst search for a known email matched nothing")
+	}
+	if n := totalItems(tokens["viewer"], "acme-user@example.com"); n != 0 {
+		t.Errorf("viewer search for a known email matched %d events, want 0", n)
+	}
+	// Searching other fields still works for viewers
+	if n := totalItems(tokens["viewer"], "acme-home"); n == 0 {
+		t.Error("viewer search for an endpoint matched nothing")
+	}
+}
+
+func TestViewerUserSeriesAreDistinctPseudonyms(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	// Both emails mask to w***@sample.com
+	var events []models.UsageEvent
+	for i, user := range []string{"walt@sample.com", "wendy@sample.com", "wendy@sample.com"} {
+		events = append(events, models.UsageEvent{
+			ID:        fmt.Sprintf("sample-%d", i),
+			CreatedAt: time.Date(2025, 1, 2, 10+i, 0, 0, 0, time.UTC),
+			CompanyID: "cmp-acme",
+			Type:      "Action",
+			Content:   "User active CMMS - Acme " + user + " /acme-home",
+			User:      user,
+		})
+	}
+	if err := server.dataService.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	series := func(path string) []string {
+		t.Helper()
+		w := serve(server, http.MethodGet, path, tokens["viewer"], "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var response models.TimeSeriesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		for _, point := range response.Data {
+			if strings.Contains(point.Series, "@") {
+				t.Errorf("point series %q shows an email", point.Series)
+			}
+		}
+		return response.Series
+	}
+
+	path := "/api/v1/trends?timeframe=daily&startDate=2025-01-01&endDate=2025-01-10&splitBy=user"
+	first := series(path)
+	seen := make(map[string]bool)
+	for _, name := range first {
+		if !strings.HasPrefix(name, "anon_") || seen[name] {
+			t.Errorf("series %q is not a distinct pseudonym in %v", name, first)
+		}
+		seen[name] = true
+	}
+	if len(first) != 4 {
+		t.Errorf("got %d series, want one per user, 4: %v", len(first), first)
+	}
+
+	// A differently keyed request, so not served from the cache
+	if again := series(path + "&tz=UTC"); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("series changed between requests: %v then %v", first, again)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expensiveRoutes scan the raw events in range on every call, rather than
+// reading rollups or lookup tables, so they draw on the smaller expensive
+// budget instead of the cheap one
+var expensiveRoutes = map[string]bool{
+	"/api/v1/events":                       true,
+	"/api/v1/companies/:id":                true,
+	"/api/v1/users/:email":                 true,
+	"/api/v1/analytics/companies":          true,
+	"/api/v1/analytics/event-distribution": true,
+	"/api/v1/analytics/top-events":         true,
+	"/api/v1/analytics/active-users":       true,
+	"/api/v1/analytics/top-endpoints":      true,
+	"/api/v1/analytics/top-companies":      true,
+	"/api/v1/analytics/retention":          true,
+	"/api/v1/analytics/paths":              true,
+	"/api/v1/analytics/adoption":           true,
+}
+
+// limitClientIP spends a token from the client IP's bucket. It runs before
+// requireScope, so requests with a missing or invalid API key are limited
+// too and keys cannot be guessed at an unbounded rate.
+func (s *Server) limitClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.ipLimiter != nil && !spendToken(c, s.ipLimiter, "ip:"+c.ClientIP(), "too many requests from this IP address; retry after the Retry-After delay") {
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimit spends a token from the caller's bucket for the route's budget
+// and rejects the request with 429 when the bucket is empty. Callers are
+// identified by API key, or by client IP without one, so it must run after
+// requireScope. Every response carries RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers for its budget.
+func (s *Server) rateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter, budget := s.cheapLimiter, "cheap"
+		if expensiveRoutes[c.FullPath()] {
+			limiter, budget = s.expensiveLimiter, "expensive"
+		}
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if key, ok := c.Get(apiKeyContextKey); ok {
+			client = "key:" + key.(auth.Key).ID
+		}
+
+		if !spendToken(c, limiter, client, "too many requests to "+budget+" routes; retry after the Retry-After delay") {
+			return
+		}
+		c.Next()
+	}
+}
+
+// spendToken takes a token from client's bucket and sets the RateLimit
+// headers for it. When the bucket is empty it aborts the request with 429,
+// a Retry-After header and message, and returns false.
+func spendToken(c *gin.Context, limiter *ratelimit.Limiter, client, message string) bool {
+	result := limiter.Allow(client)
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+
+	if !result.Allowed {
+		c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error: models.ErrorDetails{
+				Code:    "RATE_LIMITED",
+				Message: message,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// ceilSeconds formats d as whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/config"
+	"analytics-dashboard/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLimitedServer starts a server without authentication whose cheap
+// routes allow a burst of two requests per client
+func newLimitedServer(t *testing.T, trustedProxies []string) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ds, err := services.NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(&config.Config{
+		IngestConfig: config.IngestConfig{IngestBatchSize: 100, IngestFlushInterval: time.Minute},
+		RateLimitConfig: config.RateLimitConfig{
+			RateLimitCheapPerMinute: 1,
+			RateLimitCheapBurst:     2,
+			RateLimitTrustedProxies: trustedProxies,
+		},
+	}, ds)
+}
+
+// serveFrom sends a GET request from remoteAddr with an optional
+// X-Forwarded-For header
+func serveFrom(s *Server, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/event-types", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitRejectsOverBudget(t *testing.T) {
+	server := newLimitedServer(t, nil)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := serveFrom(server, "192.0.2.1:1234", "")
+		if w.Code != want {
+			t.Fatalf("request %d: status %d, want %d: %s", i+1, w.Code, want, w.Body)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("request %d: RateLimit-Limit = %q, want 2", i+1, w.Header().Get("RateLimit-Limit"))
+		}
+	}
+	if w := serveFrom(server, "192.0.2.1:1234", ""); w.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After header")
+	}
+
+	// Another client has its own bucket
+	if w := serveFrom(server, "192.0.2.2:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("other client: status %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	server := newLimitedServer(t, nil)
+
+	// A client rotating X-Forwarded-For must still share one bucket
+	for i, forwardedFor := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if w := serveFrom(server, "192.0.2.1:1234", forwardedFor); w.Code != want {
+			t.Errorf("request %d with X-Forwarded-For %s: status %d, want %d", i+1, forwardedFor, w.Code, want)
+		}
+	}
+}
+
+func TestRateLimitTrustsConfiguredProxies(t *testing.T) {
+	server := newLimitedServer(t, []string{"10.0.0.0/8"})
+
+	// Behind a trusted proxy, each forwarded client gets its own bucket
+	for i, forwardedFor := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"} {
+		if w := serveFrom(server, "10.0.0.5:1234", forwardedFor); w.Code != http.StatusOK {
+			t.Errorf("request %d via trusted proxy: status %d, want 200", i+1, w.Code)
+		}
+	}
+}
+
+func TestRateLimitPerIPAppliesBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ds, err := services.NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "api_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(&config.Config{
+		IngestConfig: config.IngestConfig{IngestBatchSize: 100, IngestFlushInterval: time.Minute},
+		RateLimitConfig: config.RateLimitConfig{
+			RateLimitPerIPPerMinute: 1,
+			RateLimitPerIPBurst:     2,
+		},
+	}, ds)
+	server.EnableAuth(keys)
+
+	// Guessed keys are rejected, then the client IP runs out of budget
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		w := serve(server, http.MethodGet, "/api/v1/event-types", "not-a-key", "")
+		if w.Code != want {
+			t.Fatalf("request %d: status %d, want %d: %s", i+1, w.Code, want, w.Body)
+		}
+	}
+}
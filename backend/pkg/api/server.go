@@ -1,11 +1,19 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"analytics-dashboard/pkg/api/handlers"
+	"analytics-dashboard/pkg/audit"
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/cache"
 	"analytics-dashboard/pkg/config"
+	"analytics-dashboard/pkg/privacy"
+	"analytics-dashboard/pkg/ratelimit"
 	"analytics-dashboard/pkg/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,58 +21,113 @@ import (
 
 // Server represents the HTTP server
 type Server struct {
-	config      *config.Config
-	dataService *services.DataService
-	router      *gin.Engine
+	config       *config.Config
+	dataService  *services.DataService
+	ingestBuffer *services.IngestBuffer
+	cache        *cache.LRU
+	keys         *auth.KeyStore // nil when authentication is disabled
+	audit        *audit.Log     // nil when audit logging is disabled
+	metrics      *serverMetrics // nil when metrics are disabled
+	router       *gin.Engine
+	http         *http.Server
+
+	// Per-client request budgets for cheap and expensive routes; nil when
+	// that budget is unlimited
+	cheapLimiter     *ratelimit.Limiter
+	expensiveLimiter *ratelimit.Limiter
+
+	// Per-IP request budget checked before the API key; nil when unlimited
+	ipLimiter *ratelimit.Limiter
 }
 
 // NewServer creates a new server instance
 func NewServer(cfg *config.Config, dataService *services.DataService) *Server {
 	server := &Server{
-		config:      cfg,
-		dataService: dataService,
-		router:      gin.Default(),
+		config:       cfg,
+		dataService:  dataService,
+		ingestBuffer: services.NewIngestBuffer(dataService, cfg.IngestBatchSize, cfg.IngestFlushInterval),
+		cache:        cache.New(cfg.CacheMaxEntries, cfg.CacheTTL),
+		router:       gin.Default(),
+
+		cheapLimiter:     ratelimit.New(cfg.RateLimitCheapPerMinute, cfg.RateLimitCheapBurst),
+		expensiveLimiter: ratelimit.New(cfg.RateLimitExpensivePerMinute, cfg.RateLimitExpensiveBurst),
+		ipLimiter:        ratelimit.New(cfg.RateLimitPerIPPerMinute, cfg.RateLimitPerIPBurst),
+	}
+
+	// gin trusts forwarding headers from every peer by default, which would
+	// let clients pick the IP they are rate limited and audited under
+	if err := server.router.SetTrustedProxies(cfg.RateLimitTrustedProxies); err != nil {
+		slog.Warn("Ignoring invalid trusted proxies", "error", err)
+		server.router.SetTrustedProxies(nil)
+	}
+
+	if cfg.MetricsEnabled {
+		server.metrics = newServerMetrics()
 	}
 
 	server.setupRoutes()
+	server.http = &http.Server{
+		Addr:         fmt.Sprintf(":%s", cfg.Port),
+		Handler:      server.router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
 	return server
 }
 
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
-	// Enable CORS for development
-	s.router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	})
-
-	// API v1 routes
-	v1 := s.router.Group("/api/v1")
+	// Count and time every request, including those rejected by later
+	// middleware
+	s.router.Use(s.observeRequests())
+
+	// Apply the configured CORS policy and answer preflight requests
+	s.router.Use(s.cors())
+
+	// API v1 routes. Every request is audited when audit logging is enabled
+	// and draws on its client IP's budget before the API key is checked.
+	// Each group then requires its API key scope when authentication is
+	// enabled and draws on the caller's rate limit budget.
+	v1 := s.router.Group("/api/v1", s.auditRequests(), s.limitClientIP())
 	{
 		// Initialize handlers
-		eventHandler := handlers.NewEventHandler(s.dataService)
+		eventHandler := handlers.NewEventHandler(s.dataService, privacy.NewPseudonyms(s.config.PseudonymSecret))
+		ingestHandler := handlers.NewIngestHandler(s.ingestBuffer)
+		catalogHandler := handlers.NewCatalogHandler(s.dataService)
+		segmentHandler := handlers.NewSegmentHandler(s.dataService)
+
+		// Query result cache for repeated analytics requests
+		cached := s.responseCache()
+		limited := s.rateLimit()
 
 		// Event routes - Unified endpoint
-		v1.GET("/events", eventHandler.GetEvents)                  // Unified search and filtering
-		v1.GET("/events/metrics", eventHandler.GetFilteredMetrics) // Filtered metrics
+		events := v1.Group("", s.requireScope(auth.ScopeReadEvents), limited)
+		events.GET("/events", eventHandler.GetEvents)                          // Unified search and filtering
+		events.GET("/events/metrics", cached, eventHandler.GetFilteredMetrics) // Filtered metrics
+		events.GET("/companies", eventHandler.GetCompanies)
+		events.GET("/status", eventHandler.GetStatus)
+		events.GET("/event-types", eventHandler.GetEventTypes)
+		events.GET("/users/:email", eventHandler.GetUserProfile)
+		events.GET("/event-catalog", catalogHandler.GetEventCatalog)
+
+		// Ingestion routes
+		ingest := v1.Group("", s.requireScope(auth.ScopeIngest), limited)
+		ingest.POST("/events/ingest", ingestHandler.IngestEvents) // Buffered ingestion
 
 		// Analytics routes
-		v1.GET("/trends", eventHandler.GetTimeSeriesData)
-		v1.GET("/trends/multi-company", eventHandler.GetMultiCompanyTrends) // New multi-company trends endpoint
-		v1.GET("/metrics", eventHandler.GetMetrics)
-		v1.GET("/companies", eventHandler.GetCompanies)
-		v1.GET("/event-types", eventHandler.GetEventTypes)
+		reports := v1.Group("", s.requireScope(auth.ScopeReadAnalytics), limited)
+		reports.GET("/trends", cached, eventHandler.GetTimeSeriesData)
+		reports.GET("/trends/multi-company", cached, eventHandler.GetMultiCompanyTrends) // New multi-company trends endpoint
+		reports.GET("/metrics", cached, eventHandler.GetMetrics)
+		reports.GET("/companies/:id", cached, eventHandler.GetCompanyDetail)
+
+		// Saved segments, referenced by the segment query parameter
+		reports.GET("/segments", segmentHandler.ListSegments)
+		reports.GET("/segments/:id", segmentHandler.GetSegment)
 
 		// Advanced analytics routes
-		analytics := v1.Group("/analytics")
+		analytics := reports.Group("/analytics", cached)
 		{
 			analytics.GET("/companies", eventHandler.GetTopActiveCompanies)
 			analytics.GET("/event-distribution", eventHandler.GetEventDistribution)
@@ -73,7 +136,18 @@ func (s *Server) setupRoutes() {
 			analytics.GET("/top-endpoints", eventHandler.GetTopEndpointsByUsage)
 			analytics.GET("/top-companies", eventHandler.GetTopActiveCompaniesWithFiltering)
 			analytics.GET("/retention", eventHandler.GetRetentionAnalytics)
+			analytics.GET("/paths", eventHandler.GetUserPaths)
+			analytics.GET("/adoption", eventHandler.GetFeatureAdoption)
 		}
+
+		// Administrative routes that change shared definitions
+		admin := v1.Group("", s.requireScope(auth.ScopeAdmin), limited)
+		admin.PUT("/event-catalog", catalogHandler.AnnotateEntry)
+		admin.POST("/segments", segmentHandler.CreateSegment)
+		admin.PUT("/segments/:id", segmentHandler.UpdateSegment)
+		admin.DELETE("/segments/:id", segmentHandler.DeleteSegment)
+		admin.GET("/admin/audit", s.getAuditLog)
+		admin.GET("/admin/config", s.getConfig)
 	}
 
 	// Health check endpoint
@@ -84,6 +158,28 @@ func (s *Server) setupRoutes() {
 		})
 	})
 
+	// Liveness probe: the process is up and serving requests
+	s.router.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Readiness probe: a dataset is loaded, so queries can be answered
+	s.router.GET("/readyz", func(c *gin.Context) {
+		if !s.dataService.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "not ready",
+				"reason": "no events are loaded",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+
+	// Prometheus metrics
+	if s.metrics != nil {
+		s.router.GET("/metrics", s.getMetrics)
+	}
+
 	// Root endpoint
 	s.router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -91,11 +187,16 @@ func (s *Server) setupRoutes() {
 			"version": "1.0.0",
 			"endpoints": gin.H{
 				"health":      "/health",
+				"liveness":    "/livez",
+				"readiness":   "/readyz",
+				"status":      "/api/v1/status",
 				"events":      "/api/v1/events",
 				"trends":      "/api/v1/trends",
 				"metrics":     "/api/v1/metrics",
 				"companies":   "/api/v1/companies",
 				"event_types": "/api/v1/event-types",
+				"catalog":     "/api/v1/event-catalog",
+				"segments":    "/api/v1/segments",
 				"analytics":   "/api/v1/analytics",
 				"retention":   "/api/v1/analytics/retention",
 			},
@@ -103,8 +204,50 @@ func (s *Server) setupRoutes() {
 	})
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%s", s.config.Port)
-	return s.router.Run(addr)
+// EnableAuth requires an API key with the route's scope on every /api/v1
+// request. Keys are looked up in keys.
+func (s *Server) EnableAuth(keys *auth.KeyStore) {
+	s.keys = keys
+}
+
+// EnableAudit records every /api/v1 request to auditLog and serves it from
+// /api/v1/admin/audit
+func (s *Server) EnableAudit(auditLog *audit.Log) {
+	s.audit = auditLog
+}
+
+// Run serves requests until ctx is cancelled and then shuts down
+// gracefully: it stops accepting connections, waits up to the configured
+// shutdown timeout for in-flight requests and flushes events still queued
+// for ingestion. Connections still open at the deadline are closed.
+func (s *Server) Run(ctx context.Context) error {
+	s.ingestBuffer.Start()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.http.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		s.ingestBuffer.Stop()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining connections", "timeout", s.config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.http.Close()
+		errs = append(errs, fmt.Errorf("failed to drain connections: %w", err))
+	}
+
+	// Requests are drained, so nothing adds to the buffer any more
+	if _, err := s.ingestBuffer.Stop(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush ingestion buffer: %w", err))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/auth"
+	"analytics-dashboard/pkg/config"
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTenantServer starts a server with authentication enabled over two
+// companies and returns it with a key for each company, an unrestricted
+// key and an unrestricted viewer key
+func newTenantServer(t *testing.T) (*Server, map[string]string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ds, err := services.NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	var events []models.UsageEvent
+	for day := 0; day < 10; day++ {
+		for _, company := range []string{"Acme", "Globex"} {
+			user := strings.ToLower(company) + "-user@example.com"
+			events = append(events, models.UsageEvent{
+				ID:        fmt.Sprintf("%s-%d", company, day),
+				CreatedAt: base.AddDate(0, 0, day),
+				CompanyID: "cmp-" + strings.ToLower(company),
+				Type:      "Action",
+				Content:   fmt.Sprintf("User active CMMS - %s %s /%s-home", company, user, strings.ToLower(company)),
+			})
+		}
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "api_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopes := []string{auth.ScopeReadEvents, auth.ScopeReadAnalytics, auth.ScopeIngest}
+	tokens := make(map[string]string)
+	for name, companyID := range map[string]string{"acme": "cmp-acme", "globex": "cmp-globex", "all": ""} {
+		token, _, err := keys.Create(name, auth.RoleAnalyst, scopes, companyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[name] = token
+	}
+	token, _, err := keys.Create("viewer", auth.RoleViewer, []string{auth.ScopeReadEvents, auth.ScopeReadAnalytics}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens["viewer"] = token
+
+	server := NewServer(&config.Config{
+		CacheConfig:  config.CacheConfig{CacheMaxEntries: 100, CacheTTL: time.Minute},
+		IngestConfig: config.IngestConfig{IngestBatchSize: 100, IngestFlushInterval: time.Minute},
+	}, ds)
+	server.EnableAuth(keys)
+	return server, tokens
+}
+
+// serve sends a request with the given API key and returns the response
+func serve(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenantKeysOnlySeeTheirCompany(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	paths := []string{
+		"/api/v1/events",
+		"/api/v1/companies",
+		"/api/v1/event-types",
+		"/api/v1/event-catalog",
+		"/api/v1/trends?timeframe=daily&startDate=2025-01-01&endDate=2025-01-10",
+		"/api/v1/trends/multi-company?timeframe=daily&startDate=2025-01-01&endDate=2025-01-10",
+		"/api/v1/metrics",
+		"/api/v1/analytics/companies",
+		"/api/v1/analytics/active-users",
+		"/api/v1/analytics/top-endpoints",
+		"/api/v1/analytics/retention?startDate=2025-01-01&endDate=2025-01-10",
+	}
+
+	// Alternate tenants on each path so a shared cache entry would show up
+	for _, path := range paths {
+		for _, tenant := range []struct{ key, own, other string }{
+			{"acme", "acme", "globex"},
+			{"globex", "globex", "acme"},
+			{"acme", "acme", "globex"},
+		} {
+			w := serve(server, http.MethodGet, path, tokens[tenant.key], "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s as %s: status %d: %s", path, tenant.key, w.Code, w.Body)
+			}
+			body := strings.ToLower(w.Body.String())
+			if strings.Contains(body, tenant.other) {
+				t.Errorf("%s as %s leaks %s: %s", path, tenant.key, tenant.other, w.Body)
+			}
+		}
+	}
+
+	// The unrestricted key still sees both companies after tenants filled the cache
+	w := serve(server, http.MethodGet, "/api/v1/analytics/companies", tokens["all"], "")
+	if body := w.Body.String(); !strings.Contains(body, "Acme") || !strings.Contains(body, "Globex") {
+		t.Errorf("unrestricted key sees %s, want both companies", body)
+	}
+}
+
+func TestTenantKeysCannotReachOtherCompanies(t *testing.T) {
+	server, tokens := newTenantServer(t)
+
+	if w := serve(server, http.MethodGet, "/api/v1/companies/cmp-globex", tokens["acme"], ""); w.Code != http.StatusNotFound {
+		t.Errorf("company detail of another tenant: status %d, want 404", w.Code)
+	}
+	if w := serve(server, http.MethodGet, "/api/v1/users/globex-user@example.com", tokens["acme"], ""); w.Code != http.StatusNotFound {
+		t.Errorf("user profile of another tenant: status %d, want 404", w.Code)
+	}
+
+	event := `{"events":[{"id":"late-1","company_id":"cmp-globex","type":"Action","created_at":"2025-01-20T09:00:00Z"}]}`
+	if w := serve(server, http.MethodPost, "/api/v1/events/ingest", tokens["acme"], event); w.Code != http.StatusForbidden {
+		t.Errorf("ingest for another tenant: status %d, want 403", w.Code)
+	}
+}
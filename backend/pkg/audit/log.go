@@ -0,0 +1,271 @@
+// Package audit records API access to an append-only JSON-lines file. The
+// file is rotated when it grows past a size limit, keeping a fixed number
+// of older files next to it as path.1 (newest) to path.N (oldest).
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is one audited request
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	KeyID     string            `json:"keyId,omitempty"`
+	KeyName   string            `json:"keyName,omitempty"`
+	Role      string            `json:"role,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	ClientIP  string            `json:"clientIp"`
+	Method    string            `json:"method"`
+	Route     string            `json:"route"` // Route template, e.g. /api/v1/companies/:id
+	Path      string            `json:"path"`
+	Query     map[string]string `json:"query,omitempty"` // Normalized query parameters
+	Status    int               `json:"status"`
+	Bytes     int               `json:"bytes"` // Response body size
+	LatencyMs float64           `json:"latencyMs"`
+}
+
+// Filter selects audit entries. Zero fields match everything.
+type Filter struct {
+	User  string // Key ID or key name
+	Route string // Route template or request path
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// matches reports whether entry passes the filter
+func (f Filter) matches(entry Entry) bool {
+	if f.User != "" && entry.KeyID != f.User && entry.KeyName != f.User {
+		return false
+	}
+	if f.Route != "" && entry.Route != f.Route && entry.Path != f.Route {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Log appends entries to a JSON-lines file, rotating it by size
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// Open opens the audit log at path for appending. The file is rotated once
+// it would grow past maxBytes, and at most maxFiles rotated files are kept.
+func Open(path string, maxBytes int64, maxFiles int) (*Log, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("audit log size limit must be positive")
+	}
+	if maxFiles < 0 {
+		return nil, fmt.Errorf("audit log file count must not be negative")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends an entry to the log
+func (l *Log) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries matching filter across the current and rotated
+// files, newest first. The files are opened under the lock and read after
+// it is released, so a long query does not hold up Record; the open files
+// survive a rotation in the meantime, and entries written after the query
+// started are left out.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	files, err := l.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFiles(files)
+
+	// Oldest file first, so matches are collected in time order
+	var matched []Entry
+	for _, f := range files {
+		entries, err := readEntries(io.LimitReader(f.file, f.size), f.file.Name(), filter)
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, entries...)
+	}
+
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched, nil
+}
+
+// openFile is a log file opened for a query, with its size at the time
+type openFile struct {
+	file *os.File
+	size int64
+}
+
+// openFiles opens the rotated and current files, oldest first, skipping
+// files that do not exist
+func (l *Log) openFiles() ([]openFile, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []openFile
+	for i := l.maxFiles; i >= 0; i-- {
+		file, err := os.Open(l.rotatedPath(i))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		files = append(files, openFile{file: file})
+
+		info, err := file.Stat()
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("failed to stat audit log: %w", err)
+		}
+		files[len(files)-1].size = info.Size()
+	}
+	return files, nil
+}
+
+// closeFiles closes files opened for a query
+func closeFiles(files []openFile) {
+	for _, f := range files {
+		f.file.Close()
+	}
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// open opens the current file for appending; callers must hold l.mu
+func (l *Log) open() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and
+// starts a new current file; callers must hold l.mu
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+
+	if l.maxFiles == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+		return l.open()
+	}
+
+	for i := l.maxFiles - 1; i >= 0; i-- {
+		err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	return l.open()
+}
+
+// rotatedPath returns the path of the i-th rotated file; 0 is the current
+// file
+func (l *Log) rotatedPath(i int) string {
+	if i == 0 {
+		return l.path
+	}
+	return l.path + "." + strconv.Itoa(i)
+}
+
+// readEntries reads the entries from one file that match filter
+func readEntries(r io.Reader, path string, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a line torn by a crash mid-write rather than fail the query
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+	return entries, nil
+}
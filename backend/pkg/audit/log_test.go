@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueryReadsRotatedFilesNewestFirst(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.log"), 512, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	base := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		entry := Entry{Time: base.Add(time.Duration(i) * time.Minute), KeyName: "wallboard", Route: "/api/v1/metrics", Path: fmt.Sprintf("/api/v1/metrics?n=%d", i)}
+		if err := l.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := l.Query(Filter{User: "wallboard", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	for i, entry := range entries {
+		if want := base.Add(time.Duration(19-i) * time.Minute); !entry.Time.Equal(want) {
+			t.Errorf("entry %d at %s, want %s", i, entry.Time, want)
+		}
+	}
+}
+
+func TestQueryDuringRotationReturnsEachEntryOnce(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.log"), 2048, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		for i := 0; i < 500; i++ {
+			if err := l.Record(Entry{Time: time.Unix(int64(i), 0), Route: "/r"}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// Queries racing rotations must never see an entry twice
+	query := func() int {
+		entries, err := l.Query(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[int64]bool)
+		for _, entry := range entries {
+			if seen[entry.Time.Unix()] {
+				t.Fatalf("entry %d returned twice", entry.Time.Unix())
+			}
+			seen[entry.Time.Unix()] = true
+		}
+		return len(entries)
+	}
+	for running := true; running; {
+		select {
+		case <-recorded:
+			running = false
+		default:
+			query()
+		}
+	}
+	if n := query(); n != 500 {
+		t.Errorf("got %d entries after recording, want 500", n)
+	}
+}
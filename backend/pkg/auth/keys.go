@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes grant access to groups of routes. ScopeAdmin grants every scope.
+const (
+	ScopeReadEvents    = "read-events"
+	ScopeReadAnalytics = "read-analytics"
+	ScopeIngest        = "ingest"
+	ScopeAdmin         = "admin"
+)
+
+// Roles decide what personal data a key may see. Admins and analysts see
+// raw user emails and event content; viewers get them masked.
+const (
+	RoleAdmin   = "admin"
+	RoleAnalyst = "analyst"
+	RoleViewer  = "viewer"
+)
+
+// roleScopes are the scopes a key of each role gets when none are given
+var roleScopes = map[string][]string{
+	RoleAdmin:   {ScopeAdmin},
+	RoleAnalyst: {ScopeReadEvents, ScopeReadAnalytics},
+	RoleViewer:  {ScopeReadEvents, ScopeReadAnalytics},
+}
+
+// Gin context keys set by the auth layer for each authenticated request
+const (
+	// TenantContextKey holds the company ID a tenant-scoped key is
+	// restricted to
+	TenantContextKey = "tenant"
+	// RoleContextKey holds the role of the request's key
+	RoleContextKey = "role"
+)
+
+// keyPrefix starts every API key so keys are easy to recognize in logs and
+// secret scanners
+const keyPrefix = "adk"
+
+var (
+	// ErrInvalidKey is returned for missing, malformed, unknown or revoked keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrKeyNotFound is returned when revoking an unknown key ID
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, known := roleScopes[role]
+	return known
+}
+
+// IsValidScope reports whether scope is a known scope
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeReadEvents, ScopeReadAnalytics, ScopeIngest, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Key is a stored API key. Only a SHA-256 hash of the secret key is kept.
+// A key with a CompanyID is a tenant key and only sees that company's data.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Role      string     `json:"role,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CompanyID string     `json:"companyId,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants scope
+func (k Key) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessRole returns the key's role. Keys created before roles existed are
+// admins if they hold the admin scope and analysts otherwise.
+func (k Key) AccessRole() string {
+	if k.Role != "" {
+		return k.Role
+	}
+	for _, scope := range k.Scopes {
+		if scope == ScopeAdmin {
+			return RoleAdmin
+		}
+	}
+	return RoleAnalyst
+}
+
+// Revoked reports whether the key has been revoked
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// KeyStore holds API keys in a JSON file. The file is reloaded when it
+// changes on disk, so keys created or revoked with the apikeys command take
+// effect without a restart.
+type KeyStore struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	keys    []Key
+}
+
+// OpenKeyStore loads the key file at path. A missing file is an empty store.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create generates a key with the given name, role and scopes and saves its
+// hash. Without scopes the key gets its role's default scopes. Only admins
+// may hold the admin scope. A non-empty companyID restricts the key to that
+// company; tenant keys cannot be admins, since admin routes change shared
+// state. The returned secret is the only copy of the full key.
+func (s *KeyStore) Create(name, role string, scopes []string, companyID string) (string, Key, error) {
+	if !IsValidRole(role) {
+		return "", Key{}, fmt.Errorf("unknown role %q", role)
+	}
+	if role == RoleAdmin && companyID != "" {
+		return "", Key{}, fmt.Errorf("company keys cannot have the %s role", RoleAdmin)
+	}
+	if len(scopes) == 0 {
+		scopes = roleScopes[role]
+	}
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return "", Key{}, fmt.Errorf("unknown scope %q", scope)
+		}
+		if scope == ScopeAdmin && role != RoleAdmin {
+			return "", Key{}, fmt.Errorf("the %s scope requires the %s role", ScopeAdmin, RoleAdmin)
+		}
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return "", Key{}, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", Key{}, err
+	}
+	token := fmt.Sprintf("%s_%s_%s", keyPrefix, id, secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return "", Key{}, err
+	}
+	key := Key{
+		ID:        id,
+		Name:      name,
+		Hash:      hashToken(token),
+		Role:      role,
+		Scopes:    scopes,
+		CompanyID: companyID,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.keys = append(s.keys, key)
+	if err := s.save(); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return "", Key{}, err
+	}
+	return token, key, nil
+}
+
+// List returns every key, including revoked ones, oldest first
+func (s *KeyStore) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys, nil
+}
+
+// Revoke marks the key with the given ID as revoked
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+	for i := range s.keys {
+		if s.keys[i].ID != id {
+			continue
+		}
+		if s.keys[i].Revoked() {
+			return nil
+		}
+		now := time.Now().UTC()
+		s.keys[i].RevokedAt = &now
+		if err := s.save(); err != nil {
+			s.keys[i].RevokedAt = nil
+			return err
+		}
+		return nil
+	}
+	return ErrKeyNotFound
+}
+
+// Authenticate returns the active key matching token
+func (s *KeyStore) Authenticate(token string) (Key, error) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return Key{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Key{}, err
+	}
+	hash := hashToken(token)
+	for _, key := range s.keys {
+		if key.ID == parts[1] && subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) == 1 {
+			if key.Revoked() {
+				return Key{}, ErrInvalidKey
+			}
+			return key, nil
+		}
+	}
+	return Key{}, ErrInvalidKey
+}
+
+// reload rereads the key file if it changed since it was last read; callers
+// must hold s.mu
+func (s *KeyStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.keys, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat API keys: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse API keys %s: %w", s.path, err)
+	}
+	s.keys, s.modTime = keys, info.ModTime()
+	return nil
+}
+
+// save writes the keys to the key file, replacing it atomically; callers
+// must hold s.mu
+func (s *KeyStore) save() error {
+	sort.SliceStable(s.keys, func(i, j int) bool {
+		return s.keys[i].CreatedAt.Before(s.keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode API keys: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create API keys directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write API keys: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace API keys: %w", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// hashToken returns the hex SHA-256 hash of a full API key
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size- and TTL-bounded least-recently-used cache. Every entry is
+// tagged with the dataset generation it was computed from, and lookups for a
+// different generation miss and evict the stale entry.
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	hits       uint64
+	misses     uint64
+}
+
+// entry is a single cached value
+type entry struct {
+	key        string
+	value      interface{}
+	generation uint64
+	expires    time.Time
+}
+
+// New creates an LRU cache holding at most maxEntries values for ttl each.
+// A ttl of zero means entries only expire through eviction or invalidation.
+func New(maxEntries int, ttl time.Duration) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value cached under key for the given generation
+func (c *LRU) Get(key string, generation uint64) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.items[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if e.generation != generation || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		c.removeElement(elem)
+		c.misses++
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	c.hits++
+	return e.value, true
+}
+
+// Add stores a value under key for the given generation, evicting the least
+// recently used entry when the cache is full
+func (c *LRU) Add(key string, value interface{}, generation uint64) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if elem, exists := c.items[key]; exists {
+		c.ll.MoveToFront(elem)
+		e := elem.Value.(*entry)
+		e.value = value
+		e.generation = generation
+		e.expires = expires
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{
+		key:        key,
+		value:      value,
+		generation: generation,
+		expires:    expires,
+	})
+
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Purge removes every entry
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Len returns the number of cached entries
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *LRU) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// removeElement removes an element; callers must hold c.mu
+func (c *LRU) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+}
@@ -1,37 +1,428 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
-// Config holds application configuration
+// Config holds application configuration. Settings come from built-in
+// defaults, then an optional YAML config file, then environment variables,
+// which take precedence. Each embedded section is a top-level key of the
+// file; see config.example.yaml.
 type Config struct {
-	Port     string
-	DataPath string
+	ServerConfig    `yaml:"server"`
+	DataConfig      `yaml:"data"`
+	ParsingConfig   `yaml:"parsing"`
+	IngestConfig    `yaml:"ingest"`
+	CacheConfig     `yaml:"cache"`
+	AuthConfig      `yaml:"auth"`
+	AuditConfig     `yaml:"audit"`
+	RateLimitConfig `yaml:"rateLimit"`
+	CORSConfig      `yaml:"cors"`
+	MetricsConfig   `yaml:"metrics"`
+	LoggingConfig   `yaml:"logging"`
+
+	File string `yaml:"-"` // Config file the settings were read from, if any
+
+	envErrors []error // Environment variables that could not be parsed
+}
+
+// ServerConfig holds the HTTP listener settings. A read, write or idle
+// timeout of 0 means none; the shutdown timeout bounds how long in-flight
+// requests may take to finish on SIGINT or SIGTERM.
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+// DataConfig locates the event dataset and the files stored next to it
+type DataConfig struct {
+	DataPath     string `yaml:"path"`
+	CatalogPath  string `yaml:"catalogPath"`
+	SegmentsPath string `yaml:"segmentsPath"`
+}
+
+// ParsingConfig holds the rules applied when events are read and queried
+type ParsingConfig struct {
+	DefaultTimezone     string            `yaml:"defaultTimezone"`
+	CompanyTimezones    map[string]string `yaml:"companyTimezones"`
+	WeekStart           string            `yaml:"weekStart"`
+	FeatureMap          map[string]string `yaml:"featureMap"`
+	IdentityAliasesPath string            `yaml:"identityAliasesPath"`
+	IdentityMergeRules  []string          `yaml:"identityMergeRules"`
+}
+
+// IngestConfig holds the ingestion buffer settings
+type IngestConfig struct {
+	IngestBatchSize     int           `yaml:"batchSize"`
+	IngestFlushInterval time.Duration `yaml:"flushInterval"`
+}
+
+// CacheConfig holds the query cache settings
+type CacheConfig struct {
+	CacheMaxEntries int           `yaml:"maxEntries"`
+	CacheTTL        time.Duration `yaml:"ttl"`
+}
+
+// AuthConfig holds the API key settings. The pseudonym secret keys the
+// user IDs shown to viewers; without one they change on every restart.
+type AuthConfig struct {
+	AuthEnabled     bool   `yaml:"enabled"`
+	APIKeysPath     string `yaml:"keysPath"`
+	PseudonymSecret string `yaml:"pseudonymSecret"`
 }
 
-// Load loads configuration from environment variables and defaults
-func Load() *Config {
-	port := getEnv("PORT", "8080")
-	dataPath := getEnv("DATA_PATH", "data/dataset.csv")
+// AuditConfig holds the audit log settings; an empty path disables it
+type AuditConfig struct {
+	AuditLogPath     string `yaml:"path"`
+	AuditLogMaxBytes int    `yaml:"maxBytes"`
+	AuditLogMaxFiles int    `yaml:"maxFiles"`
+}
+
+// RateLimitConfig holds token-bucket rate limits per API key, or per client
+// IP without one. Expensive routes scan raw events on every call; all other
+// routes are cheap. A per-IP limit also applies to every request before its
+// API key is checked, so invalid keys cannot be tried without limit. A rate
+// of 0 disables that limit. The client IP is only taken from
+// X-Forwarded-For and X-Real-IP when the connection comes from one of the
+// trusted proxies, given as IP addresses or CIDR ranges.
+type RateLimitConfig struct {
+	RateLimitCheapPerMinute     int      `yaml:"cheapPerMinute"`
+	RateLimitCheapBurst         int      `yaml:"cheapBurst"`
+	RateLimitExpensivePerMinute int      `yaml:"expensivePerMinute"`
+	RateLimitExpensiveBurst     int      `yaml:"expensiveBurst"`
+	RateLimitPerIPPerMinute     int      `yaml:"perIPPerMinute"`
+	RateLimitPerIPBurst         int      `yaml:"perIPBurst"`
+	RateLimitTrustedProxies     []string `yaml:"trustedProxies"`
+}
+
+// CORSConfig holds the cross-origin policy. An origin of "*" allows any
+// origin and "https://*.example.com" any subdomain; a max age of 0 leaves
+// preflight caching to the browser.
+type CORSConfig struct {
+	CORSAllowedOrigins   []string      `yaml:"allowedOrigins"`
+	CORSAllowedMethods   []string      `yaml:"allowedMethods"`
+	CORSAllowedHeaders   []string      `yaml:"allowedHeaders"`
+	CORSExposedHeaders   []string      `yaml:"exposedHeaders"`
+	CORSAllowCredentials bool          `yaml:"allowCredentials"`
+	CORSMaxAge           time.Duration `yaml:"maxAge"`
+}
+
+// MetricsConfig holds the Prometheus metrics settings
+type MetricsConfig struct {
+	MetricsEnabled bool `yaml:"enabled"`
+}
+
+// LoggingConfig holds the server log settings. The level is debug, info,
+// warn or error, and the format is text or json.
+type LoggingConfig struct {
+	LogLevel  string `yaml:"level"`
+	LogFormat string `yaml:"format"`
+}
+
+// Load builds the configuration from the defaults, the YAML file at path
+// and the environment, and validates it. An empty path falls back to
+// CONFIG_FILE; without either, only defaults and environment are used.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
+	}
+
+	cfg.applyEnv()
+	cfg.resolvePaths()
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaults returns the built-in configuration. Paths derived from the data
+// path and the feature map are filled in by resolvePaths once the data path
+// is final.
+func defaults() *Config {
+	return &Config{
+		ServerConfig: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    time.Minute,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		DataConfig: DataConfig{DataPath: "data/dataset.csv"},
+		ParsingConfig: ParsingConfig{
+			DefaultTimezone: "UTC",
+			WeekStart:       "monday",
+		},
+		IngestConfig: IngestConfig{
+			IngestBatchSize:     500,
+			IngestFlushInterval: 2 * time.Second,
+		},
+		CacheConfig: CacheConfig{
+			CacheMaxEntries: 1000,
+			CacheTTL:        5 * time.Minute,
+		},
+		AuditConfig: AuditConfig{
+			AuditLogMaxBytes: 10 << 20,
+			AuditLogMaxFiles: 5,
+		},
+		RateLimitConfig: RateLimitConfig{
+			RateLimitCheapPerMinute:     600,
+			RateLimitCheapBurst:         60,
+			RateLimitExpensivePerMinute: 30,
+			RateLimitExpensiveBurst:     5,
+			RateLimitPerIPPerMinute:     1200,
+			RateLimitPerIPBurst:         120,
+		},
+		CORSConfig: CORSConfig{
+			CORSAllowedOrigins: []string{"*"},
+			CORSAllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			CORSAllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
+			CORSExposedHeaders: []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+			CORSMaxAge:         10 * time.Minute,
+		},
+		MetricsConfig: MetricsConfig{MetricsEnabled: true},
+		LoggingConfig: LoggingConfig{LogLevel: "info", LogFormat: "text"},
+	}
+}
+
+// readFile overlays the settings in a YAML file. Keys the file omits keep
+// their current values, and unknown keys are rejected so typos do not go
+// unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides settings with the environment variables that are set.
+// Values that do not parse are kept for Validate to report.
+func (c *Config) applyEnv() {
+	c.Port = getEnv("PORT", c.Port)
+	c.ReadTimeout = c.getEnvDuration("SERVER_READ_TIMEOUT", c.ReadTimeout)
+	c.WriteTimeout = c.getEnvDuration("SERVER_WRITE_TIMEOUT", c.WriteTimeout)
+	c.IdleTimeout = c.getEnvDuration("SERVER_IDLE_TIMEOUT", c.IdleTimeout)
+	c.ShutdownTimeout = c.getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	c.DataPath = getEnv("DATA_PATH", c.DataPath)
+	c.CatalogPath = getEnv("CATALOG_PATH", c.CatalogPath)
+	c.SegmentsPath = getEnv("SEGMENTS_PATH", c.SegmentsPath)
+
+	c.DefaultTimezone = getEnv("DEFAULT_TIMEZONE", c.DefaultTimezone)
+	c.CompanyTimezones = getEnvMapOrDefault("COMPANY_TIMEZONES", c.CompanyTimezones)
+	c.WeekStart = getEnv("WEEK_START", c.WeekStart)
+	c.FeatureMap = getEnvMapOrDefault("FEATURE_MAP", c.FeatureMap)
+	c.IdentityAliasesPath = getEnv("IDENTITY_ALIASES_PATH", c.IdentityAliasesPath)
+	c.IdentityMergeRules = getEnvListOrDefault("IDENTITY_MERGE_RULES", c.IdentityMergeRules)
+
+	c.IngestBatchSize = c.getEnvInt("INGEST_BATCH_SIZE", c.IngestBatchSize)
+	c.IngestFlushInterval = c.getEnvDuration("INGEST_FLUSH_INTERVAL", c.IngestFlushInterval)
+
+	c.CacheMaxEntries = c.getEnvInt("CACHE_MAX_ENTRIES", c.CacheMaxEntries)
+	c.CacheTTL = c.getEnvDuration("CACHE_TTL", c.CacheTTL)
+
+	c.AuthEnabled = c.getEnvBool("AUTH_ENABLED", c.AuthEnabled)
+	c.APIKeysPath = getEnv("API_KEYS_PATH", c.APIKeysPath)
+	c.PseudonymSecret = getEnv("PSEUDONYM_SECRET", c.PseudonymSecret)
+
+	c.AuditLogPath = getEnv("AUDIT_LOG_PATH", c.AuditLogPath)
+	c.AuditLogMaxBytes = c.getEnvInt("AUDIT_LOG_MAX_BYTES", c.AuditLogMaxBytes)
+	c.AuditLogMaxFiles = c.getEnvInt("AUDIT_LOG_MAX_FILES", c.AuditLogMaxFiles)
+
+	c.RateLimitCheapPerMinute = c.getEnvInt("RATE_LIMIT_CHEAP_PER_MINUTE", c.RateLimitCheapPerMinute)
+	c.RateLimitCheapBurst = c.getEnvInt("RATE_LIMIT_CHEAP_BURST", c.RateLimitCheapBurst)
+	c.RateLimitExpensivePerMinute = c.getEnvInt("RATE_LIMIT_EXPENSIVE_PER_MINUTE", c.RateLimitExpensivePerMinute)
+	c.RateLimitExpensiveBurst = c.getEnvInt("RATE_LIMIT_EXPENSIVE_BURST", c.RateLimitExpensiveBurst)
+	c.RateLimitPerIPPerMinute = c.getEnvInt("RATE_LIMIT_PER_IP_PER_MINUTE", c.RateLimitPerIPPerMinute)
+	c.RateLimitPerIPBurst = c.getEnvInt("RATE_LIMIT_PER_IP_BURST", c.RateLimitPerIPBurst)
+	c.RateLimitTrustedProxies = getEnvListOrDefault("RATE_LIMIT_TRUSTED_PROXIES", c.RateLimitTrustedProxies)
+
+	c.CORSAllowedOrigins = getEnvListOrDefault("CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins)
+	c.CORSAllowedMethods = getEnvListOrDefault("CORS_ALLOWED_METHODS", c.CORSAllowedMethods)
+	c.CORSAllowedHeaders = getEnvListOrDefault("CORS_ALLOWED_HEADERS", c.CORSAllowedHeaders)
+	c.CORSExposedHeaders = getEnvListOrDefault("CORS_EXPOSED_HEADERS", c.CORSExposedHeaders)
+	c.CORSAllowCredentials = c.getEnvBool("CORS_ALLOW_CREDENTIALS", c.CORSAllowCredentials)
+	c.CORSMaxAge = c.getEnvDuration("CORS_MAX_AGE", c.CORSMaxAge)
+
+	c.MetricsEnabled = c.getEnvBool("METRICS_ENABLED", c.MetricsEnabled)
+
+	c.LogLevel = strings.ToLower(getEnv("LOG_LEVEL", c.LogLevel))
+	c.LogFormat = strings.ToLower(getEnv("LOG_FORMAT", c.LogFormat))
+}
+
+// resolvePaths makes the data path absolute and fills in the settings that
+// default relative to it
+func (c *Config) resolvePaths() {
 	// Ensure data path is absolute
-	if !filepath.IsAbs(dataPath) {
+	if !filepath.IsAbs(c.DataPath) {
 		// Get current working directory
 		cwd, err := os.Getwd()
 		if err != nil {
 			cwd = "."
 		}
-		dataPath = filepath.Join(cwd, dataPath)
+		c.DataPath = filepath.Join(cwd, c.DataPath)
 	}
 
-	return &Config{
-		Port:     port,
-		DataPath: dataPath,
+	dataDir := filepath.Dir(c.DataPath)
+	if c.CatalogPath == "" {
+		c.CatalogPath = filepath.Join(dataDir, "event_catalog.json")
+	}
+	if c.SegmentsPath == "" {
+		c.SegmentsPath = filepath.Join(dataDir, "segments.json")
+	}
+	if c.APIKeysPath == "" {
+		c.APIKeysPath = filepath.Join(dataDir, "api_keys.json")
+	}
+	if c.FeatureMap == nil {
+		c.FeatureMap = defaultFeatureMap
 	}
 }
 
+// Validate reports every invalid setting, naming each by its config file
+// key, or by its environment variable when that did not parse. Settings
+// checked when they are applied, such as time zones and the week start,
+// are left to their consumers.
+func (c *Config) Validate() error {
+	errs := append([]error(nil), c.envErrors...)
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "server.port: %q is not a port number", c.Port)
+	check(c.ReadTimeout >= 0, "server.readTimeout: must not be negative, got %s", c.ReadTimeout)
+	check(c.WriteTimeout >= 0, "server.writeTimeout: must not be negative, got %s", c.WriteTimeout)
+	check(c.IdleTimeout >= 0, "server.idleTimeout: must not be negative, got %s", c.IdleTimeout)
+	check(c.ShutdownTimeout > 0, "server.shutdownTimeout: must be positive, got %s", c.ShutdownTimeout)
+
+	check(c.IngestBatchSize > 0, "ingest.batchSize: must be positive, got %d", c.IngestBatchSize)
+	check(c.IngestFlushInterval > 0, "ingest.flushInterval: must be positive, got %s", c.IngestFlushInterval)
+
+	check(c.CacheMaxEntries >= 0, "cache.maxEntries: must not be negative, got %d", c.CacheMaxEntries)
+	check(c.CacheTTL >= 0, "cache.ttl: must not be negative, got %s", c.CacheTTL)
+
+	check(c.PseudonymSecret == "" || len(c.PseudonymSecret) >= minPseudonymSecret, "auth.pseudonymSecret: must be at least %d characters", minPseudonymSecret)
+
+	check(c.AuditLogPath == "" || c.AuditLogMaxBytes > 0, "audit.maxBytes: must be positive, got %d", c.AuditLogMaxBytes)
+	check(c.AuditLogMaxFiles >= 0, "audit.maxFiles: must not be negative, got %d", c.AuditLogMaxFiles)
+
+	check(c.RateLimitCheapPerMinute >= 0, "rateLimit.cheapPerMinute: must not be negative, got %d", c.RateLimitCheapPerMinute)
+	check(c.RateLimitCheapBurst >= 0, "rateLimit.cheapBurst: must not be negative, got %d", c.RateLimitCheapBurst)
+	check(c.RateLimitExpensivePerMinute >= 0, "rateLimit.expensivePerMinute: must not be negative, got %d", c.RateLimitExpensivePerMinute)
+	check(c.RateLimitExpensiveBurst >= 0, "rateLimit.expensiveBurst: must not be negative, got %d", c.RateLimitExpensiveBurst)
+	check(c.RateLimitPerIPPerMinute >= 0, "rateLimit.perIPPerMinute: must not be negative, got %d", c.RateLimitPerIPPerMinute)
+	check(c.RateLimitPerIPBurst >= 0, "rateLimit.perIPBurst: must not be negative, got %d", c.RateLimitPerIPBurst)
+	for _, proxy := range c.RateLimitTrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "rateLimit.trustedProxies: %q is not an IP address or CIDR range", proxy)
+	}
+
+	explicitOrigin := false
+	for _, origin := range c.CORSAllowedOrigins {
+		check(validOrigin(origin), "cors.allowedOrigins: %q is not *, an origin like https://app.example.com or a pattern like https://*.example.com", origin)
+		explicitOrigin = explicitOrigin || origin != "*"
+	}
+	check(!c.CORSAllowCredentials || explicitOrigin, "cors.allowCredentials: requires explicit allowedOrigins, since * is ignored with credentials")
+	check(c.CORSMaxAge >= 0, "cors.maxAge: must not be negative, got %s", c.CORSMaxAge)
+
+	check(logLevels[c.LogLevel], "logging.level: %q is not debug, info, warn or error", c.LogLevel)
+	check(c.LogFormat == "text" || c.LogFormat == "json", "logging.format: %q is not text or json", c.LogFormat)
+
+	return errors.Join(errs...)
+}
+
+// minPseudonymSecret is the shortest accepted pseudonym secret, so it
+// cannot be brute-forced from known user IDs
+const minPseudonymSecret = 16
+
+// logLevels are the accepted logging.level values
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// validOrigin reports whether origin is "*" or a scheme and host with an
+// optional port and a "*." wildcard subdomain
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(strings.TrimRight(origin, "/"), "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.User == nil && u.Path == "" && u.RawQuery == "" && u.Fragment == ""
+}
+
+// redactedValue replaces secret settings in Redacted output
+const redactedValue = "[redacted]"
+
+// Redacted returns the settings in the nested layout of the config file,
+// with secrets replaced by "[redacted]". The pseudonym secret is one, and
+// so are the API key store and audit log paths, since they locate key
+// hashes and the access trail; new secret settings must be added here.
+func (c *Config) Redacted() (map[string]interface{}, error) {
+	redacted := *c
+	for _, secret := range []*string{&redacted.APIKeysPath, &redacted.AuditLogPath, &redacted.PseudonymSecret} {
+		if *secret != "" {
+			*secret = redactedValue
+		}
+	}
+
+	// Round-trip through YAML so keys and durations read as in the file
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return settings, nil
+}
+
+// defaultFeatureMap maps the CMMS endpoint templates to product features
+var defaultFeatureMap = map[string]string{
+	"/work-orders/*":                   "Work Orders",
+	"/work_orders/*":                   "Work Orders",
+	"/create_work_order/*":             "Work Orders",
+	"/equipment/*":                     "Equipment",
+	"/inventory/*":                     "Inventory",
+	"/reporting/*":                     "Reporting",
+	"/notifications/*":                 "Notifications",
+	"/settings/*":                      "Settings",
+	"/user/*":                          "Settings",
+	"/google_auth_signin_callback/*":   "Sign-in",
+	"/microsoft_signin_callback_url/*": "Sign-in",
+}
+
 // getEnv gets environment variable with fallback default
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -39,3 +430,93 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt gets an integer environment variable with fallback default,
+// recording a value that is not an integer
+func (c *Config) getEnvInt(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Errorf("%s: %q is not an integer", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool gets a boolean environment variable with fallback default,
+// recording a value that is not a boolean
+func (c *Config) getEnvBool(key string, defaultValue bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Errorf("%s: %q is not true or false", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration gets a duration environment variable (e.g. "2s") with
+// fallback default, recording a value that is not a duration
+func (c *Config) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Errorf("%s: %q is not a duration with a unit, e.g. 30s or 5m", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList parses a comma-separated list, e.g. "strip_plus,gmail_dots"
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvListOrDefault parses a comma-separated list like getEnvList,
+// falling back to defaultValue when the variable is unset or empty
+func getEnvListOrDefault(key string, defaultValue []string) []string {
+	if result := getEnvList(key); len(result) > 0 {
+		return result
+	}
+	return defaultValue
+}
+
+// getEnvMap parses a comma-separated list of key=value pairs, e.g.
+// "Facebook=America/Los_Angeles,Sample=America/New_York"
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		if name, value = strings.TrimSpace(name), strings.TrimSpace(value); name != "" && value != "" {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// getEnvMapOrDefault parses a key=value list like getEnvMap, falling back
+// to defaultValue when the variable is unset or empty
+func getEnvMapOrDefault(key string, defaultValue map[string]string) map[string]string {
+	if result := getEnvMap(key); len(result) > 0 {
+		return result
+	}
+	return defaultValue
+}
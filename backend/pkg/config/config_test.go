@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadReportsUnparsableEnvironment(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CACHE_TTL", "5")
+	t.Setenv("INGEST_BATCH_SIZE", "abc")
+	t.Setenv("METRICS_ENABLED", "maybe")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("Load accepted unparsable environment variables")
+	}
+	for _, want := range []string{"CACHE_TTL", "INGEST_BATCH_SIZE", "METRICS_ENABLED", "logging.format"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoadAppliesParsableEnvironment(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CACHE_TTL", "90s")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CacheTTL.Seconds() != 90 {
+		t.Errorf("CacheTTL = %s, want 1m30s", cfg.CacheTTL)
+	}
+	if cfg.LogLevel != "debug" || cfg.LogFormat != "text" {
+		t.Errorf("logging = %s/%s, want debug/text", cfg.LogLevel, cfg.LogFormat)
+	}
+}
@@ -0,0 +1,283 @@
+// Package metrics collects counters, gauges and histograms and writes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are histogram upper bounds in seconds suited to request
+// latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// family is one named metric with its samples
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics in the order they were registered
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+	names    map[string]bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	v := newVec("counter", name, help, labels)
+	r.register(name, v)
+	return v
+}
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	v := newVec("gauge", name, help, labels)
+	r.register(name, v)
+	return v
+}
+
+// Histogram registers a histogram with the given bucket upper bounds, which
+// must be sorted, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		header:  header{name: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	r.register(name, h)
+	return h
+}
+
+// register adds a family, panicking on a duplicate name since that is a
+// programming error
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// Write writes every metric in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// header is the name, help text and label names shared by a family
+type header struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// writeHeader writes the HELP and TYPE lines
+func (h header) writeHeader(w *bufio.Writer, kind string) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(h.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", h.name, help, h.name, kind)
+}
+
+// labelPairs formats label names and values as name="value" pairs
+func (h header) labelPairs(values []string) []string {
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = h.labels[i] + `="` + escapeLabel(value) + `"`
+	}
+	return pairs
+}
+
+// seriesKey identifies a label value combination, checking its arity
+func (h header) seriesKey(values []string) string {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", h.name, len(h.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Vec is a counter or gauge, with one sample per label value combination
+type Vec struct {
+	header
+	kind string
+
+	mu      sync.Mutex
+	samples map[string]*sample
+}
+
+// sample is one series of a Vec
+type sample struct {
+	labels []string
+	value  float64
+}
+
+func newVec(kind, name, help string, labels []string) *Vec {
+	return &Vec{
+		header:  header{name: name, help: help, labels: labels},
+		kind:    kind,
+		samples: make(map[string]*sample),
+	}
+}
+
+// Inc adds 1 to the series for the label values
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Add adds delta to the series for the label values
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(labelValues).value += delta
+}
+
+// Set sets the series for the label values. On a counter it must only be
+// used to mirror a total that is already monotonic.
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(labelValues).value = value
+}
+
+// get returns the series for the label values, creating it; callers must
+// hold v.mu
+func (v *Vec) get(labelValues []string) *sample {
+	key := v.seriesKey(labelValues)
+	s := v.samples[key]
+	if s == nil {
+		s = &sample{labels: append([]string(nil), labelValues...)}
+		v.samples[key] = s
+	}
+	return s
+}
+
+func (v *Vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.writeHeader(w, v.kind)
+	for _, key := range sortedKeys(v.samples) {
+		s := v.samples[key]
+		writeSample(w, v.name, v.labelPairs(s.labels), s.value)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets, with one
+// histogram per label value combination
+type HistogramVec struct {
+	header
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+// histogram is one series of a HistogramVec
+type histogram struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value in the series for the label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.seriesKey(labelValues)
+	s := h.series[key]
+	if s == nil {
+		s = &histogram{
+			labels: append([]string(nil), labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		pairs := h.labelPairs(s.labels)
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", append(pairs, `le="`+formatFloat(bound)+`"`), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", append(pairs, `le="+Inf"`), float64(s.count))
+		writeSample(w, h.name+"_sum", pairs, s.sum)
+		writeSample(w, h.name+"_count", pairs, float64(s.count))
+	}
+}
+
+// writeSample writes one sample line
+func writeSample(w *bufio.Writer, name string, pairs []string, value float64) {
+	w.WriteString(name)
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// formatFloat formats a value as the exposition format expects
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabel escapes backslashes, quotes and newlines in a label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// sortedKeys returns map keys in order, so output is stable between scrapes
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
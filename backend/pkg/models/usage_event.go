@@ -71,21 +71,28 @@ type SearchAggregations struct {
 
 // EventTypeCount represents event type count information
 type EventTypeCount struct {
-	Type  string `json:"type"`
-	Count int    `json:"count"`
+	Type       string      `json:"type"`
+	Count      int         `json:"count"`
+	ValueStats *ValueStats `json:"valueStats,omitempty"`
 }
 
 // TimeSeriesData represents time series data point
 type TimeSeriesData struct {
-	Timestamp string `json:"timestamp"`
-	Value     int    `json:"value"`
-	EventType string `json:"eventType"`
+	Timestamp string   `json:"timestamp"`
+	Series    string   `json:"series,omitempty"`    // Set when the series is split by a dimension
+	Value     *float64 `json:"value"`               // Null for a measure over a bucket without non-null values
+	NullCount *int     `json:"nullCount,omitempty"` // Events with a null value; set for measures
+	EventType string   `json:"eventType"`
 }
 
 // TimeSeriesResponse represents time series response
 type TimeSeriesResponse struct {
 	Data        []TimeSeriesData `json:"data"`
 	Timeframe   string           `json:"timeframe"`
+	Timezone    string           `json:"timezone,omitempty"`
+	SplitBy     string           `json:"splitBy,omitempty"`
+	Series      []string         `json:"series,omitempty"`  // Series names, largest first
+	Measure     string           `json:"measure,omitempty"` // Set when value is a measure other than count
 	TotalPoints int              `json:"totalPoints"`
 }
 
@@ -93,6 +100,7 @@ type TimeSeriesResponse struct {
 type MultiCompanyTimeSeriesResponse struct {
 	Data        []map[string]interface{} `json:"data"`
 	Timeframe   string                   `json:"timeframe"`
+	Timezone    string                   `json:"timezone,omitempty"`
 	TotalPoints int                      `json:"totalPoints"`
 }
 
@@ -102,6 +110,29 @@ type MetricsResponse struct {
 	ActiveCompanies int              `json:"activeCompanies"`
 	TopEventTypes   []EventTypeCount `json:"topEventTypes"`
 	TimeRange       TimeRange        `json:"timeRange"`
+	UniqueUsers     *ApproxCount     `json:"uniqueUsers,omitempty"` // Only set in approximate mode
+	ValueStats      *ValueStats      `json:"valueStats,omitempty"`  // Only set when value stats are requested
+}
+
+// ValueStats summarizes the numeric value column. Null values are excluded
+// from the measures and counted in NullCount.
+type ValueStats struct {
+	Count     int     `json:"count"`
+	NullCount int     `json:"nullCount"`
+	Sum       float64 `json:"sum"`
+	Avg       float64 `json:"avg"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P99       float64 `json:"p99"`
+}
+
+// ApproxCount represents an approximate distinct count and its ~95% error bound
+type ApproxCount struct {
+	Estimate      int     `json:"estimate"`
+	ErrorBound    int     `json:"errorBound"`
+	RelativeError float64 `json:"relativeError"`
 }
 
 // TimeRange represents a time range
@@ -148,29 +179,35 @@ type EventDistribution struct {
 
 // UserActivity represents user activity data
 type UserActivity struct {
-	User         string    `json:"user"`
-	EventCount   int       `json:"eventCount"`
-	Companies    int       `json:"companies"`
-	CompanyNames []string  `json:"companyNames"`
-	LastActivity time.Time `json:"lastActivity"`
+	UserID       string      `json:"userId"`
+	User         string      `json:"user"`
+	EventCount   int         `json:"eventCount"`
+	Companies    int         `json:"companies"`
+	CompanyNames []string    `json:"companyNames"`
+	LastActivity time.Time   `json:"lastActivity"`
+	ValueStats   *ValueStats `json:"valueStats,omitempty"`
 }
 
 // EndpointActivity represents endpoint activity data
 type EndpointActivity struct {
-	Endpoint     string  `json:"endpoint"`
-	EventCount   int     `json:"eventCount"`
-	UserCount    int     `json:"userCount"`
-	CompanyCount int     `json:"companyCount"`
-	Percentage   float64 `json:"percentage"`
+	Endpoint            string      `json:"endpoint"`
+	EventCount          int         `json:"eventCount"`
+	UserCount           int         `json:"userCount"`
+	UserCountErrorBound int         `json:"userCountErrorBound,omitempty"` // Only set in approximate mode
+	CompanyCount        int         `json:"companyCount"`
+	Percentage          float64     `json:"percentage"`
+	ValueStats          *ValueStats `json:"valueStats,omitempty"`
 }
 
 // CompanyActivity represents company activity data
 type CompanyActivity struct {
-	CompanyName   string    `json:"companyName"`
-	EventCount    int       `json:"eventCount"`
-	UserCount     int       `json:"userCount"`
-	EndpointCount int       `json:"endpointCount"`
-	LastActivity  time.Time `json:"lastActivity"`
+	CompanyName         string      `json:"companyName"`
+	EventCount          int         `json:"eventCount"`
+	UserCount           int         `json:"userCount"`
+	UserCountErrorBound int         `json:"userCountErrorBound,omitempty"` // Only set in approximate mode
+	EndpointCount       int         `json:"endpointCount"`
+	LastActivity        time.Time   `json:"lastActivity"`
+	ValueStats          *ValueStats `json:"valueStats,omitempty"`
 }
 
 // CompanyActivityResponse represents company activity response
@@ -240,3 +277,222 @@ type RetentionRequest struct {
 	CohortPeriod  string `json:"cohortPeriod,omitempty"` // "daily", "weekly", "monthly"
 	MinCohortSize int    `json:"minCohortSize,omitempty"`
 }
+
+// IngestRequest represents a batch of events submitted for ingestion
+type IngestRequest struct {
+	Events []UsageEvent `json:"events" binding:"required"`
+}
+
+// IngestResponse represents the result of an ingestion request
+type IngestResponse struct {
+	Accepted int `json:"accepted"`
+	Pending  int `json:"pending"`
+}
+
+// CatalogEntry describes one type/attribute pair seen in the data
+type CatalogEntry struct {
+	Type          string    `json:"type"`
+	Attribute     string    `json:"attribute"`
+	FirstSeen     time.Time `json:"firstSeen"`
+	LastSeen      time.Time `json:"lastSeen"`
+	Volume        int       `json:"volume"`
+	DistinctUsers int       `json:"distinctUsers"`
+	SampleContent []string  `json:"sampleContent"` // Most recent distinct contents
+	Description   string    `json:"description"`
+	Owner         string    `json:"owner"`
+}
+
+// CatalogResponse represents the event catalog response
+type CatalogResponse struct {
+	Data  []CatalogEntry `json:"data"`
+	Total int            `json:"total"`
+}
+
+// CatalogAnnotation is the editable part of a catalog entry
+type CatalogAnnotation struct {
+	Type        string    `json:"type" binding:"required"`
+	Attribute   string    `json:"attribute"`
+	Description string    `json:"description"`
+	Owner       string    `json:"owner"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// PathRequest represents a navigation path analysis request. Exactly one of
+// StartEndpoint and EndEndpoint anchors the paths.
+type PathRequest struct {
+	StartEndpoint string   `json:"startEndpoint,omitempty"`
+	EndEndpoint   string   `json:"endEndpoint,omitempty"`
+	Steps         int      `json:"steps,omitempty"`
+	Limit         int      `json:"limit,omitempty"`
+	Companies     []string `json:"companies,omitempty"`
+	StartDate     string   `json:"startDate,omitempty"`
+	EndDate       string   `json:"endDate,omitempty"`
+}
+
+// PathSequence represents one distinct sequence of endpoint templates
+type PathSequence struct {
+	Steps      []string `json:"steps"`
+	Count      int      `json:"count"`
+	Percentage float64  `json:"percentage"`
+}
+
+// SankeyNode represents an endpoint at a step relative to the anchor
+// (negative steps precede it)
+type SankeyNode struct {
+	ID       string `json:"id"`
+	Endpoint string `json:"endpoint"`
+	Step     int    `json:"step"`
+}
+
+// SankeyLink represents the number of paths moving between two nodes
+type SankeyLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Value  int    `json:"value"`
+}
+
+// PathAnalysisResponse represents the most common paths from or to an
+// endpoint, with Sankey nodes and links built from the returned sequences
+type PathAnalysisResponse struct {
+	Anchor     string         `json:"anchor"`
+	Direction  string         `json:"direction"` // "forward" or "backward"
+	Steps      int            `json:"steps"`
+	TotalPaths int            `json:"totalPaths"`
+	Sequences  []PathSequence `json:"sequences"`
+	Nodes      []SankeyNode   `json:"nodes"`
+	Links      []SankeyLink   `json:"links"`
+}
+
+// FeatureAdoption represents one company's use of one product feature
+type FeatureAdoption struct {
+	Feature       string     `json:"feature"`
+	DistinctUsers int        `json:"distinctUsers"`
+	AdoptionRate  float64    `json:"adoptionRate"` // Percentage of the company's active users
+	EventCount    int        `json:"eventCount"`
+	FirstUsed     *time.Time `json:"firstUsed,omitempty"`
+	LastUsed      *time.Time `json:"lastUsed,omitempty"`
+}
+
+// CompanyAdoption represents one row of the company x feature matrix
+type CompanyAdoption struct {
+	CompanyName     string            `json:"companyName"`
+	ActiveUsers     int               `json:"activeUsers"`
+	AdoptedFeatures int               `json:"adoptedFeatures"`
+	Features        []FeatureAdoption `json:"features"` // One entry per feature, in the order of AdoptionResponse.Features
+}
+
+// AdoptionResponse represents the feature adoption report
+type AdoptionResponse struct {
+	Features       []string          `json:"features"`
+	Companies      []CompanyAdoption `json:"companies"`
+	UnmappedEvents int               `json:"unmappedEvents"` // Events whose endpoint matches no feature
+}
+
+// DailyCount represents the number of events on one day
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// EndpointCount represents the number of events on one endpoint
+type EndpointCount struct {
+	Endpoint string `json:"endpoint"`
+	Count    int    `json:"count"`
+}
+
+// SessionSummary summarizes a user's sessions, split at 30 minutes of
+// inactivity
+type SessionSummary struct {
+	Count                  int       `json:"count"`
+	AvgDurationSeconds     float64   `json:"avgDurationSeconds"`
+	LongestDurationSeconds float64   `json:"longestDurationSeconds"`
+	AvgEventsPerSession    float64   `json:"avgEventsPerSession"`
+	LastSessionStart       time.Time `json:"lastSessionStart"`
+}
+
+// UserProfileResponse represents a single user's profile and activity
+type UserProfileResponse struct {
+	UserID       string          `json:"userId"`
+	User         string          `json:"user"`    // Canonical email
+	Aliases      []string        `json:"aliases"` // Raw user values seen in the range
+	Companies    []string        `json:"companies"`
+	FirstSeen    time.Time       `json:"firstSeen"`
+	LastSeen     time.Time       `json:"lastSeen"`
+	TotalEvents  int             `json:"totalEvents"`
+	EventsByDay  []DailyCount    `json:"eventsByDay"`
+	TopEndpoints []EndpointCount `json:"topEndpoints"`
+	Sessions     SessionSummary  `json:"sessions"`
+	Timeline     []UsageEvent    `json:"timeline"` // Newest first
+	Pagination   PaginationInfo  `json:"pagination"`
+}
+
+// RetentionSummary condenses retention analytics to headline numbers
+type RetentionSummary struct {
+	CohortPeriod     string  `json:"cohortPeriod"`
+	TotalCohorts     int     `json:"totalCohorts"`
+	AverageRetention float64 `json:"averageRetention"`
+}
+
+// CompanyDetailResponse represents a single company's drill-down view
+type CompanyDetailResponse struct {
+	CompanyID    string             `json:"companyId"`
+	CompanyName  string             `json:"companyName"`
+	StartDate    string             `json:"startDate"`
+	EndDate      string             `json:"endDate"`
+	TotalEvents  int                `json:"totalEvents"`
+	ActiveUsers  int                `json:"activeUsers"`
+	LastActivity *time.Time         `json:"lastActivity,omitempty"`
+	Trend        TimeSeriesResponse `json:"trend"`
+	TopUsers     []UserActivity     `json:"topUsers"`
+	TopEndpoints []EndpointActivity `json:"topEndpoints"`
+	Retention    RetentionSummary   `json:"retention"`
+}
+
+// Segment is a named, saved set of users. A user belongs to the segment when
+// every rule matches.
+type Segment struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Rules       []SegmentRule `json:"rules"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+// SegmentRule matches users on one field. Event fields (company, eventType,
+// endpoint, eventCount) consider only events within the last WindowDays days
+// of data when WindowDays is set.
+type SegmentRule struct {
+	Field      string `json:"field"`    // company, eventType, endpoint, email, emailDomain or eventCount
+	Operator   string `json:"operator"` // eq, neq, in or contains; eq, gt, gte, lt or lte for eventCount
+	Value      string `json:"value"`    // Comma-separated for in
+	WindowDays int    `json:"windowDays,omitempty"`
+}
+
+// SegmentResponse is a segment with its current number of members
+type SegmentResponse struct {
+	Segment
+	Users int `json:"users"`
+}
+
+// SegmentsResponse represents the list of saved segments
+type SegmentsResponse struct {
+	Data  []SegmentResponse `json:"data"`
+	Total int               `json:"total"`
+}
+
+// DataStatus describes the loaded dataset. Event times are nil while no
+// events are loaded.
+type DataStatus struct {
+	Ready         bool       `json:"ready"`  // Whether any events are loaded
+	Source        string     `json:"source"` // CSV file the dataset was loaded from
+	TotalEvents   int        `json:"totalEvents"`
+	Companies     int        `json:"companies"`
+	EventTypes    int        `json:"eventTypes"`
+	EarliestEvent *time.Time `json:"earliestEvent"`
+	LatestEvent   *time.Time `json:"latestEvent"`
+	SkippedRows   int        `json:"skippedRows"` // CSV rows that could not be read or parsed
+	LoadedAt      *time.Time `json:"loadedAt"`
+	UpdatedAt     *time.Time `json:"updatedAt"` // Last load or ingestion flush
+	Generation    uint64     `json:"generation"`
+}
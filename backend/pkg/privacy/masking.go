@@ -0,0 +1,155 @@
+// Package privacy shapes API responses for callers that may not see
+// personal data. User emails are masked to their first letter and domain,
+// so "wendy@sample.com" becomes "w***@sample.com", and emails inside raw
+// event content are redacted. Stable user IDs are replaced with keyed
+// pseudonyms, so masked users can still be told apart within a response
+// but not looked up or matched to a guessed email.
+package privacy
+
+import (
+	"regexp"
+	"strings"
+
+	"analytics-dashboard/pkg/models"
+	"analytics-dashboard/pkg/services"
+)
+
+// redacted replaces emails found in free text
+const redacted = "[redacted]"
+
+// emailPattern matches email addresses embedded in event content
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// MaskEmail keeps the first character of the local part and the domain of
+// an email. Values without an "@", such as placeholders for unknown users,
+// are returned unchanged.
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return email
+	}
+	return local[:1] + "***@" + domain
+}
+
+// RedactEmails replaces every email in text with a placeholder
+func RedactEmails(text string) string {
+	return emailPattern.ReplaceAllString(text, redacted)
+}
+
+// MaskEvent masks the user of an event and redacts emails from its content
+func MaskEvent(event models.UsageEvent) models.UsageEvent {
+	event.User = MaskEmail(event.User)
+	event.Content = RedactEmails(event.Content)
+	return event
+}
+
+// MaskEvents returns masked copies of events
+func MaskEvents(events []models.UsageEvent) []models.UsageEvent {
+	masked := make([]models.UsageEvent, len(events))
+	for i, event := range events {
+		masked[i] = MaskEvent(event)
+	}
+	return masked
+}
+
+// MaskUserActivities returns copies of users with their emails masked and
+// their IDs replaced with pseudonyms
+func MaskUserActivities(users []models.UserActivity, ids *Pseudonyms) []models.UserActivity {
+	masked := make([]models.UserActivity, len(users))
+	for i, user := range users {
+		user.User = MaskEmail(user.User)
+		user.UserID = ids.UserID(user.UserID)
+		masked[i] = user
+	}
+	return masked
+}
+
+// MaskTimeSeries relabels the series of a time series split by user with
+// the pseudonyms of the users' IDs, which userID resolves from the series
+// emails. Masked emails could give two users the same label, so they are
+// not used. Series that are not emails, such as "Other" and "Unknown", keep
+// their names.
+func MaskTimeSeries(response models.TimeSeriesResponse, ids *Pseudonyms, userID func(user string) string) models.TimeSeriesResponse {
+	if response.SplitBy != services.SplitByUser {
+		return response
+	}
+
+	labels := make(map[string]string, len(response.Series))
+	label := func(name string) string {
+		if !strings.Contains(name, "@") {
+			return name
+		}
+		if _, done := labels[name]; !done {
+			labels[name] = ids.UserID(userID(name))
+		}
+		return labels[name]
+	}
+
+	series := make([]string, len(response.Series))
+	for i, name := range response.Series {
+		series[i] = label(name)
+	}
+	response.Series = series
+
+	data := make([]models.TimeSeriesData, len(response.Data))
+	for i, point := range response.Data {
+		point.Series = label(point.Series)
+		data[i] = point
+	}
+	response.Data = data
+	return response
+}
+
+// MaskCompanyDetail masks the users and trend of a company drill-down
+func MaskCompanyDetail(detail models.CompanyDetailResponse, ids *Pseudonyms, userID func(user string) string) models.CompanyDetailResponse {
+	detail.TopUsers = MaskUserActivities(detail.TopUsers, ids)
+	detail.Trend = MaskTimeSeries(detail.Trend, ids, userID)
+	return detail
+}
+
+// MaskCatalog redacts emails from the sample contents of catalog entries
+func MaskCatalog(response models.CatalogResponse) models.CatalogResponse {
+	data := make([]models.CatalogEntry, len(response.Data))
+	for i, entry := range response.Data {
+		samples := make([]string, len(entry.SampleContent))
+		for j, content := range entry.SampleContent {
+			samples[j] = RedactEmails(content)
+		}
+		entry.SampleContent = samples
+		data[i] = entry
+	}
+	response.Data = data
+	return response
+}
+
+// MaskSegment masks the values of a segment's email rules. Values of
+// contains rules may be partial emails, so those are masked too.
+func MaskSegment(segment models.SegmentResponse) models.SegmentResponse {
+	rules := make([]models.SegmentRule, len(segment.Rules))
+	for i, rule := range segment.Rules {
+		if rule.Field == services.SegmentFieldEmail {
+			values := strings.Split(rule.Value, ",")
+			for j, value := range values {
+				value = strings.TrimSpace(value)
+				if !strings.Contains(value, "@") && value != "" {
+					value += "@"
+				}
+				values[j] = strings.TrimSuffix(MaskEmail(value), "@")
+			}
+			rule.Value = strings.Join(values, ",")
+		}
+		rules[i] = rule
+	}
+	segment.Rules = rules
+	return segment
+}
+
+// MaskSegments masks every segment in a list
+func MaskSegments(response models.SegmentsResponse) models.SegmentsResponse {
+	data := make([]models.SegmentResponse, len(response.Data))
+	for i, segment := range response.Data {
+		data[i] = MaskSegment(segment)
+	}
+	response.Data = data
+	return response
+}
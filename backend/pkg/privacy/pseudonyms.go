@@ -0,0 +1,40 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Pseudonyms derives the user IDs shown to callers that may not see
+// personal data. Stable user IDs are a plain hash of the email, so anyone
+// guessing an email could confirm it offline; pseudonyms are keyed with a
+// server secret instead, and cannot be checked without it.
+type Pseudonyms struct {
+	key []byte
+}
+
+// NewPseudonyms creates pseudonyms keyed with secret. Without a secret a
+// random key is used, so pseudonyms change whenever the server restarts.
+func NewPseudonyms(secret string) *Pseudonyms {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			panic("privacy: failed to generate a pseudonym key: " + err.Error())
+		}
+	}
+	return &Pseudonyms{key: key}
+}
+
+// UserID returns the pseudonym of a stable user ID. Empty IDs, which mark
+// unknown users, stay empty.
+func (p *Pseudonyms) UserID(id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(id))
+	return "anon_" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
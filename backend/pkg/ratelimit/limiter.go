@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped, so
+// clients that stop calling do not accumulate
+const sweepInterval = time.Minute
+
+// Limiter is a set of per-client token buckets sharing one rate and burst.
+// Each bucket holds up to burst tokens, refills continuously at the rate and
+// spends one token per request.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // Tokens per second
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is one client's tokens as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Result describes a client's budget after a request
+type Result struct {
+	Allowed    bool
+	Limit      int           // Bucket size
+	Remaining  int           // Whole tokens left
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next request is allowed; zero when allowed
+}
+
+// New creates a limiter allowing perMinute requests per client on average,
+// with bursts of up to burst requests. It returns nil, meaning no limit,
+// when perMinute is not positive. A burst below 1 is raised to 1.
+func New(perMinute, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:      float64(perMinute) / 60,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow spends a token from client's bucket if one is available
+func (l *Limiter) Allow(client string) Result {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b := l.buckets[client]
+	if b == nil {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = l.refilled(b, now)
+	b.updated = now
+
+	result := Result{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.timeFor(1 - b.tokens)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = l.timeFor(l.burst - b.tokens)
+	return result
+}
+
+// refilled returns the tokens in b at now; callers must hold l.mu
+func (l *Limiter) refilled(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.updated).Seconds()*l.rate
+	return math.Min(tokens, l.burst)
+}
+
+// timeFor returns how long it takes to refill the given number of tokens
+func (l *Limiter) timeFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be identical; callers must hold l.mu
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for client, b := range l.buckets {
+		if l.refilled(b, now) >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWithoutRateIsUnlimited(t *testing.T) {
+	if l := New(0, 10); l != nil {
+		t.Errorf("New(0, 10) = %v, want nil", l)
+	}
+}
+
+func TestAllowSpendsBurstThenRejects(t *testing.T) {
+	l := New(60, 3)
+
+	for i := 0; i < 3; i++ {
+		result := l.Allow("client")
+		if !result.Allowed {
+			t.Fatalf("request %d rejected within the burst", i+1)
+		}
+		if result.Limit != 3 || result.Remaining != 2-i {
+			t.Errorf("request %d: limit %d remaining %d, want 3 and %d", i+1, result.Limit, result.Remaining, 2-i)
+		}
+	}
+
+	result := l.Allow("client")
+	if result.Allowed {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	// One token a second, so the next request is allowed within a second
+	// and the bucket is full within three
+	if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %s, want (0, 1s]", result.RetryAfter)
+	}
+	if result.Reset <= 2*time.Second || result.Reset > 3*time.Second {
+		t.Errorf("Reset = %s, want (2s, 3s]", result.Reset)
+	}
+}
+
+func TestAllowKeepsClientsApart(t *testing.T) {
+	l := New(60, 1)
+
+	if !l.Allow("a").Allowed {
+		t.Fatal("first request from a rejected")
+	}
+	if l.Allow("a").Allowed {
+		t.Fatal("second request from a allowed")
+	}
+	if !l.Allow("b").Allowed {
+		t.Error("b was limited by a's requests")
+	}
+}
+
+func TestAllowRefills(t *testing.T) {
+	l := New(60000, 1) // A token every millisecond
+
+	if !l.Allow("client").Allowed {
+		t.Fatal("first request rejected")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !l.Allow("client").Allowed {
+		t.Error("request after the refill time rejected")
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// featureRule maps endpoint templates matching pattern to a feature name
+type featureRule struct {
+	pattern string
+	feature string
+}
+
+// ConfigureFeatures sets the endpoint patterns that define product features.
+// Patterns are matched against endpoint templates; "*" matches any run of
+// characters, and a trailing "/*" also matches the bare prefix, so
+// "/work-orders/*" covers "/work-orders" and "/work-orders/:id". When
+// several patterns match, the most specific (longest) one wins.
+func (ds *DataService) ConfigureFeatures(features map[string]string) error {
+	rules := make([]featureRule, 0, len(features))
+	for pattern, feature := range features {
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("feature pattern %q must start with /", pattern)
+		}
+		if feature == "" {
+			return fmt.Errorf("feature pattern %q has no feature name", pattern)
+		}
+		rules = append(rules, featureRule{pattern: pattern, feature: feature})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].pattern < rules[j].pattern
+	})
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.features = rules
+	return nil
+}
+
+// featureFor returns the feature an endpoint template belongs to
+func (ds *DataService) featureFor(template string) (string, bool) {
+	for _, rule := range ds.features {
+		if matchEndpointPattern(rule.pattern, template) {
+			return rule.feature, true
+		}
+	}
+	return "", false
+}
+
+// matchEndpointPattern reports whether template matches a feature pattern
+func matchEndpointPattern(pattern, template string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && template == prefix {
+		return true
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(template, parts[0]) {
+		return false
+	}
+	rest := template[len(parts[0]):]
+	if len(parts) == 1 {
+		return rest == ""
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx == -1 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[len(parts)-1])
+}
+
+// featureUsage accumulates one company's use of one feature
+type featureUsage struct {
+	users      map[string]bool
+	eventCount int
+	first      time.Time
+	last       time.Time
+}
+
+// GetFeatureAdoption returns a company x feature matrix of distinct users,
+// event counts and first/last use, with endpoints mapped to features by
+// ConfigureFeatures
+func (ds *DataService) GetFeatureAdoption(startDate, endDate string, companies []string, opts ...QueryOption) models.AdoptionResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	features := ds.featureNames()
+	response := models.AdoptionResponse{
+		Features:  features,
+		Companies: []models.CompanyAdoption{},
+	}
+	if !ds.loaded {
+		return response
+	}
+
+	// Resolve each template once; templates repeat heavily
+	templateFeatures := make(map[string]string)
+	templateMapped := make(map[string]bool)
+
+	usage := make(map[string]map[string]*featureUsage) // company -> feature -> usage
+	activeUsers := make(map[string]map[string]bool)    // company -> users
+
+	for _, event := range ds.selectEvents(startDate, endDate, companies, q) {
+		companyName := companyNameFor(ds.companies, event.CompanyID)
+		if activeUsers[companyName] == nil {
+			activeUsers[companyName] = make(map[string]bool)
+			usage[companyName] = make(map[string]*featureUsage)
+		}
+		if userID := ds.userID(event.User); userID != "" {
+			activeUsers[companyName][userID] = true
+		}
+
+		template := endpointTemplate(event.Endpoint)
+		if _, seen := templateMapped[template]; !seen {
+			templateFeatures[template], templateMapped[template] = ds.featureFor(template)
+		}
+		if !templateMapped[template] {
+			response.UnmappedEvents++
+			continue
+		}
+
+		feature := templateFeatures[template]
+		u := usage[companyName][feature]
+		if u == nil {
+			u = &featureUsage{users: make(map[string]bool), first: event.CreatedAt}
+			usage[companyName][feature] = u
+		}
+		u.eventCount++
+		if userID := ds.userID(event.User); userID != "" {
+			u.users[userID] = true
+		}
+		// Events are in CreatedAt order
+		u.last = event.CreatedAt
+	}
+
+	for companyName, users := range activeUsers {
+		row := models.CompanyAdoption{
+			CompanyName: companyName,
+			ActiveUsers: len(users),
+			Features:    make([]models.FeatureAdoption, len(features)),
+		}
+
+		for i, feature := range features {
+			cell := models.FeatureAdoption{Feature: feature}
+			if u := usage[companyName][feature]; u != nil {
+				first, last := u.first, u.last
+				cell.DistinctUsers = len(u.users)
+				cell.EventCount = u.eventCount
+				cell.FirstUsed = &first
+				cell.LastUsed = &last
+				if len(users) > 0 {
+					cell.AdoptionRate = float64(len(u.users)) / float64(len(users)) * 100
+				}
+				row.AdoptedFeatures++
+			}
+			row.Features[i] = cell
+		}
+
+		response.Companies = append(response.Companies, row)
+	}
+
+	sort.Slice(response.Companies, func(i, j int) bool {
+		return response.Companies[i].CompanyName < response.Companies[j].CompanyName
+	})
+
+	return response
+}
+
+// featureNames returns the distinct configured feature names, sorted
+func (ds *DataService) featureNames() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, rule := range ds.features {
+		if !seen[rule.feature] {
+			seen[rule.feature] = true
+			names = append(names, rule.feature)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// scanRollupCells calls fn for every rollup cell in the date range that the
+// query's tenant may see. A materialized table is used when one aligns with
+// the range and no segment is requested; otherwise the matching raw events
+// are aggregated into a temporary table first.
+func (ds *DataService) scanRollupCells(startDate, endDate string, q queryOptions, fn func(bucket time.Time, dims rollupDims, cell *rollupCell)) {
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	if q.segment == nil {
+		if table := ds.rollups.tableFor(start, end, q.location, 24*time.Hour); table != nil {
+			table.scan(start, end, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+				if q.tenant == "" || dims.companyID == q.tenant {
+					fn(bucket, dims, cell)
+				}
+			})
+			return
+		}
+	}
+
+	table := newRollupTable(24 * time.Hour)
+	for _, event := range ds.selectEvents(startDate, endDate, nil, q) {
+		table.add(event, rollupDimsFor(event), ds.userID(event.User))
+	}
+	table.scan(time.Time{}, time.Time{}, fn)
+}
+
+// ApproxUniqueUsersCount estimates distinct users by merging the HyperLogLog
+// sketches stored in each matching rollup cell
+func (ds *DataService) ApproxUniqueUsersCount(startDate, endDate string, companies, eventTypes []string, opts ...QueryOption) models.ApproxCount {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	users := newHLLSketch()
+	filter := newRollupFilter(companies, eventTypes, "")
+	ds.scanRollupCells(startDate, endDate, q, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if ds.rollupMatches(filter, dims) {
+			users.merge(cell.users)
+		}
+	})
+
+	return users.approxCount()
+}
+
+// ApproxTopEndpointsByUsage returns top endpoint templates by usage with
+// distinct users estimated from rollup sketches
+func (ds *DataService) ApproxTopEndpointsByUsage(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.EndpointActivity {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	if !ds.loaded {
+		return []models.EndpointActivity{}
+	}
+
+	type endpointStats struct {
+		eventCount int
+		users      *hllSketch
+		companies  map[string]bool
+	}
+
+	totalEvents := 0
+	stats := make(map[string]*endpointStats)
+	filter := newRollupFilter(companies, nil, "")
+	ds.scanRollupCells(startDate, endDate, q, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if !ds.rollupMatches(filter, dims) {
+			return
+		}
+		totalEvents += cell.count
+
+		s := stats[dims.endpoint]
+		if s == nil {
+			s = &endpointStats{users: newHLLSketch(), companies: make(map[string]bool)}
+			stats[dims.endpoint] = s
+		}
+		s.eventCount += cell.count
+		s.users.merge(cell.users)
+		s.companies[dims.companyID] = true
+	})
+
+	var topEndpoints []models.EndpointActivity
+	for endpoint, s := range stats {
+		users := s.users.approxCount()
+		topEndpoints = append(topEndpoints, models.EndpointActivity{
+			Endpoint:            endpoint,
+			EventCount:          s.eventCount,
+			UserCount:           users.Estimate,
+			UserCountErrorBound: users.ErrorBound,
+			CompanyCount:        len(s.companies),
+			Percentage:          float64(s.eventCount) / float64(totalEvents) * 100,
+		})
+	}
+
+	// Sort by event count descending
+	sort.Slice(topEndpoints, func(i, j int) bool {
+		return topEndpoints[i].EventCount > topEndpoints[j].EventCount
+	})
+
+	// Limit results
+	if limit > 0 && len(topEndpoints) > limit {
+		topEndpoints = topEndpoints[:limit]
+	}
+
+	return topEndpoints
+}
+
+// ApproxTopActiveCompanies returns top active companies with distinct users
+// estimated from rollup sketches. Endpoints are counted by template.
+func (ds *DataService) ApproxTopActiveCompanies(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.CompanyActivity {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	if !ds.loaded {
+		return []models.CompanyActivity{}
+	}
+
+	type companyStats struct {
+		eventCount   int
+		users        *hllSketch
+		endpoints    map[string]bool
+		lastActivity time.Time
+	}
+
+	stats := make(map[string]*companyStats)
+	filter := newRollupFilter(companies, nil, "")
+	ds.scanRollupCells(startDate, endDate, q, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if !ds.rollupMatches(filter, dims) {
+			return
+		}
+
+		companyName := companyNameFor(ds.companies, dims.companyID)
+		s := stats[companyName]
+		if s == nil {
+			s = &companyStats{users: newHLLSketch(), endpoints: make(map[string]bool)}
+			stats[companyName] = s
+		}
+		s.eventCount += cell.count
+		s.users.merge(cell.users)
+		s.endpoints[dims.endpoint] = true
+		if cell.last.After(s.lastActivity) {
+			s.lastActivity = cell.last
+		}
+	})
+
+	var topCompanies []models.CompanyActivity
+	for companyName, s := range stats {
+		users := s.users.approxCount()
+		topCompanies = append(topCompanies, models.CompanyActivity{
+			CompanyName:         companyName,
+			EventCount:          s.eventCount,
+			UserCount:           users.Estimate,
+			UserCountErrorBound: users.ErrorBound,
+			EndpointCount:       len(s.endpoints),
+			LastActivity:        s.lastActivity,
+		})
+	}
+
+	// Sort by event count descending
+	sort.Slice(topCompanies, func(i, j int) bool {
+		return topCompanies[i].EventCount > topCompanies[j].EventCount
+	})
+
+	// Limit results
+	if limit > 0 && len(topCompanies) > limit {
+		topCompanies = topCompanies[:limit]
+	}
+
+	return topCompanies
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// Dimensions a time series can be split by
+const (
+	SplitByCompany   = "company"
+	SplitByUser      = "user"
+	SplitByEndpoint  = "endpoint"
+	SplitByType      = "type"
+	SplitByAttribute = "attribute"
+)
+
+// OtherSeries names the series that collects everything outside the top N
+const OtherSeries = "Other"
+
+// IsValidSplitBy reports whether splitBy is a supported breakdown dimension
+func IsValidSplitBy(splitBy string) bool {
+	switch splitBy {
+	case SplitByCompany, SplitByUser, SplitByEndpoint, SplitByType, SplitByAttribute:
+		return true
+	}
+	return false
+}
+
+// seriesCounts holds per-bucket counts for each series of a breakdown
+type seriesCounts struct {
+	buckets map[string]map[string]int // series -> bucket key -> count
+	totals  map[string]int            // series -> count over the whole range
+}
+
+// newSeriesCounts creates empty breakdown counts
+func newSeriesCounts() *seriesCounts {
+	return &seriesCounts{
+		buckets: make(map[string]map[string]int),
+		totals:  make(map[string]int),
+	}
+}
+
+// add records count events for series in the bucket keyed by key
+func (sc *seriesCounts) add(series, key string, count int) {
+	if sc.buckets[series] == nil {
+		sc.buckets[series] = make(map[string]int)
+	}
+	sc.buckets[series][key] += count
+	sc.totals[series] += count
+}
+
+// ranked returns the series ordered by total volume, largest first
+func (sc *seriesCounts) ranked() []string {
+	names := make([]string, 0, len(sc.totals))
+	for name := range sc.totals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if sc.totals[names[i]] != sc.totals[names[j]] {
+			return sc.totals[names[i]] > sc.totals[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// topSeries ranks the series by volume and splits them into the top N to
+// keep and the rest to fold into OtherSeries; top <= 0 keeps every series.
+// A real series named like OtherSeries is not ranked. It is listed last and
+// absorbs the folded series, so a response never has two series of that
+// name.
+func (sc *seriesCounts) topSeries(top int) (series, folded []string) {
+	ranked := sc.ranked()
+	_, hasOther := sc.totals[OtherSeries]
+	if hasOther {
+		for i, name := range ranked {
+			if name == OtherSeries {
+				ranked = append(ranked[:i:i], ranked[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if top > 0 && len(ranked) > top {
+		return append(ranked[:top:top], OtherSeries), ranked[top:]
+	}
+	if hasOther {
+		ranked = append(ranked, OtherSeries)
+	}
+	return ranked, nil
+}
+
+// GetTimeSeriesBreakdown returns time series data split into one series per
+// value of splitBy. The top series by total volume are kept and the rest are
+// summed into an "Other" series, along with any value that is itself
+// "Other"; top <= 0 keeps every series. Points are
+// returned in long format, one per bucket and series.
+func (ds *DataService) GetTimeSeriesBreakdown(timeframe, startDate, endDate string, companies, eventTypes []string, splitBy string, top int, opts ...QueryOption) models.TimeSeriesResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	if !ds.loaded {
+		return models.TimeSeriesResponse{
+			Data:        []models.TimeSeriesData{},
+			Timeframe:   timeframe,
+			SplitBy:     splitBy,
+			TotalPoints: 0,
+		}
+	}
+
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	b := newBucketer(timeframe, q)
+
+	counts, ok := ds.breakdownFromRollups(b, start, end, companies, eventTypes, splitBy, q)
+	if !ok {
+		counts = ds.breakdownFromEvents(b, startDate, endDate, companies, eventTypes, splitBy, q)
+	}
+
+	// Keep the top series and fold the remainder into "Other"
+	series, folded := counts.topSeries(top)
+	for _, name := range folded {
+		for key, count := range counts.buckets[name] {
+			counts.add(OtherSeries, key, count)
+		}
+	}
+
+	var data []models.TimeSeriesData
+	for _, timestamp := range b.series(start, end) {
+		for _, name := range series {
+			eventType := eventTypeLabel(eventTypes)
+			if splitBy == SplitByType {
+				eventType = name
+			}
+			data = append(data, models.TimeSeriesData{
+				Timestamp: timestamp,
+				Series:    name,
+				Value:     pointValue(float64(counts.buckets[name][timestamp])),
+				EventType: eventType,
+			})
+		}
+	}
+
+	return models.TimeSeriesResponse{
+		Data:        data,
+		Timeframe:   b.timeframe,
+		Timezone:    q.location.String(),
+		SplitBy:     splitBy,
+		Series:      series,
+		TotalPoints: len(data),
+	}
+}
+
+// breakdownFromRollups counts series from the rollup tables. Only the
+// company, type and endpoint dimensions are rolled up, so ok is false for
+// other dimensions, segmented queries or when no table aligns with the
+// range.
+func (ds *DataService) breakdownFromRollups(b bucketer, start, end time.Time, companies, eventTypes []string, splitBy string, q queryOptions) (*seriesCounts, bool) {
+	if splitBy != SplitByCompany && splitBy != SplitByType && splitBy != SplitByEndpoint {
+		return nil, false
+	}
+	if q.segment != nil {
+		return nil, false
+	}
+	table := ds.rollups.tableFor(start, end, b.location, b.finestWidth())
+	if table == nil {
+		return nil, false
+	}
+
+	filter := newRollupFilter(companies, eventTypes, q.tenant)
+	counts := newSeriesCounts()
+	table.scan(start, end, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if !ds.rollupMatches(filter, dims) {
+			return
+		}
+
+		var name string
+		switch splitBy {
+		case SplitByCompany:
+			name = companyNameFor(ds.companies, dims.companyID)
+		case SplitByType:
+			name = dims.eventType
+		default:
+			name = dims.endpoint
+		}
+		counts.add(name, b.key(bucket), cell.count)
+	})
+
+	return counts, true
+}
+
+// breakdownFromEvents counts series by scanning raw events
+func (ds *DataService) breakdownFromEvents(b bucketer, startDate, endDate string, companies, eventTypes []string, splitBy string, q queryOptions) *seriesCounts {
+	filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
+
+	counts := newSeriesCounts()
+	for _, event := range filtered {
+		counts.add(ds.splitValue(event, splitBy), b.key(event.CreatedAt), 1)
+	}
+
+	return counts
+}
+
+// splitValue returns the series an event belongs to when split by splitBy.
+// Endpoints are grouped by template to match the rollup tables.
+func (ds *DataService) splitValue(event models.UsageEvent, splitBy string) string {
+	var value string
+	switch splitBy {
+	case SplitByCompany:
+		value = companyNameFor(ds.companies, event.CompanyID)
+	case SplitByUser:
+		value = ds.userEmail(event.User)
+	case SplitByEndpoint:
+		value = endpointTemplate(event.Endpoint)
+	case SplitByType:
+		value = event.Type
+	case SplitByAttribute:
+		value = event.Attribute
+	}
+	if value == "" {
+		return "Unknown"
+	}
+	return value
+}
+
+// eventTypeLabel describes the event types a series covers: the single
+// requested type, or "All" when the series is not limited to one type
+func eventTypeLabel(eventTypes []string) string {
+	if len(eventTypes) == 1 {
+		return eventTypes[0]
+	}
+	return "All"
+}
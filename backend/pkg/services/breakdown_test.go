@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// newAttributeDataService loads events on one day whose attributes occur
+// the given number of times
+func newAttributeDataService(t *testing.T, attributes map[string]int) *DataService {
+	t.Helper()
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	var events []models.UsageEvent
+	for attribute, count := range attributes {
+		for i := 0; i < count; i++ {
+			events = append(events, models.UsageEvent{
+				ID:        fmt.Sprintf("event-%d", len(events)),
+				CreatedAt: base.Add(time.Duration(len(events)) * time.Minute),
+				CompanyID: "cmp-test",
+				Type:      "Action",
+				Content:   "User active CMMS - Test user@test.com /home",
+				User:      "user@test.com",
+				Attribute: attribute,
+			})
+		}
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+// seriesValues sums the points of a response by series
+func seriesValues(response models.TimeSeriesResponse) map[string]float64 {
+	values := make(map[string]float64)
+	for _, point := range response.Data {
+		values[point.Series] += *point.Value
+	}
+	return values
+}
+
+func TestBreakdownFoldsRealOtherSeriesIntoOther(t *testing.T) {
+	ds := newAttributeDataService(t, map[string]int{"Other": 10, "a": 5, "b": 3, "c": 2})
+
+	response := ds.GetTimeSeriesBreakdown(TimeframeDaily, "2025-04-01", "2025-04-01", nil, nil, SplitByAttribute, 2)
+	want := []string{"a", "b", OtherSeries}
+	if fmt.Sprint(response.Series) != fmt.Sprint(want) {
+		t.Fatalf("series = %v, want %v", response.Series, want)
+	}
+	if len(response.Data) != len(want) {
+		t.Errorf("%d points for one day, want one per series: %+v", len(response.Data), response.Data)
+	}
+	if values := seriesValues(response); values[OtherSeries] != 12 {
+		t.Errorf("Other = %v, want the real Other series plus c, 12", values[OtherSeries])
+	}
+
+	// Without folding, a real Other series is kept as is and listed last
+	response = ds.GetTimeSeriesBreakdown(TimeframeDaily, "2025-04-01", "2025-04-01", nil, nil, SplitByAttribute, 0)
+	want = []string{"a", "b", "c", OtherSeries}
+	if fmt.Sprint(response.Series) != fmt.Sprint(want) {
+		t.Errorf("series = %v, want %v", response.Series, want)
+	}
+	if values := seriesValues(response); values[OtherSeries] != 10 {
+		t.Errorf("Other = %v, want 10", values[OtherSeries])
+	}
+}
+
+func TestMeasureFoldsRealOtherSeriesIntoOther(t *testing.T) {
+	ds := newAttributeDataService(t, map[string]int{"Other": 10, "a": 5, "b": 3, "c": 2})
+
+	response := ds.GetTimeSeriesMeasure(TimeframeDaily, "2025-04-01", "2025-04-01", nil, nil, SplitByAttribute, MeasureCount, 2)
+	want := []string{"a", "b", OtherSeries}
+	if fmt.Sprint(response.Series) != fmt.Sprint(want) {
+		t.Fatalf("series = %v, want %v", response.Series, want)
+	}
+	if values := seriesValues(response); values[OtherSeries] != 12 {
+		t.Errorf("Other count = %v, want 12", values[OtherSeries])
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeframes supported by the time-series endpoints
+const (
+	TimeframeHourly    = "hourly"
+	TimeframeDaily     = "daily"
+	TimeframeWeekly    = "weekly"
+	TimeframeMonthly   = "monthly"
+	TimeframeQuarterly = "quarterly"
+)
+
+// IsValidTimeframe reports whether timeframe is a supported bucket granularity
+func IsValidTimeframe(timeframe string) bool {
+	switch timeframe {
+	case TimeframeHourly, TimeframeDaily, TimeframeWeekly, TimeframeMonthly, TimeframeQuarterly:
+		return true
+	}
+	return false
+}
+
+// maxBuckets caps the buckets a time series may span per timeframe, so gap
+// filling stays bounded however wide the requested range is
+var maxBuckets = map[string]int{
+	TimeframeHourly:    24 * 366,
+	TimeframeDaily:     366 * 10,
+	TimeframeWeekly:    53 * 20,
+	TimeframeMonthly:   12 * 50,
+	TimeframeQuarterly: 4 * 50,
+}
+
+// ErrInvalidDate is returned for dates that are not YYYY-MM-DD or a range
+// that ends before it starts
+var ErrInvalidDate = errors.New("invalid date")
+
+// ErrRangeTooLarge is returned for a date range spanning more buckets than
+// the timeframe allows
+var ErrRangeTooLarge = errors.New("date range too large")
+
+// ValidateDateRange checks that startDate and endDate are YYYY-MM-DD dates,
+// that the range does not end before it starts and, for a timeframe, that
+// it spans at most the timeframe's bucket limit
+func ValidateDateRange(timeframe, startDate, endDate string) error {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return fmt.Errorf("%w: startDate %q is not a YYYY-MM-DD date", ErrInvalidDate, startDate)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return fmt.Errorf("%w: endDate %q is not a YYYY-MM-DD date", ErrInvalidDate, endDate)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("%w: endDate %s is before startDate %s", ErrInvalidDate, endDate, startDate)
+	}
+
+	limit, ok := maxBuckets[timeframe]
+	if !ok {
+		return nil
+	}
+	b := bucketer{timeframe: timeframe, location: time.UTC, weekStart: time.Monday}
+	count := 0
+	for bucket := b.floor(start); bucket.Before(end.AddDate(0, 0, 1)); bucket = b.next(bucket) {
+		if count++; count > limit {
+			return fmt.Errorf("%w: a %s series may span at most %d buckets", ErrRangeTooLarge, timeframe, limit)
+		}
+	}
+	return nil
+}
+
+// clampDateRange returns a start date no earlier than endDate's timeframe
+// bucket limit allows, so a defaulted range passes ValidateDateRange. The
+// dates must already be valid YYYY-MM-DD dates.
+func clampDateRange(timeframe, startDate, endDate string) string {
+	if !errors.Is(ValidateDateRange(timeframe, startDate, endDate), ErrRangeTooLarge) {
+		return startDate
+	}
+
+	end, _ := time.Parse("2006-01-02", endDate)
+	limit := maxBuckets[timeframe]
+	var start time.Time
+	switch timeframe {
+	case TimeframeHourly:
+		start = end.AddDate(0, 0, -(limit/24 - 1))
+	case TimeframeWeekly:
+		start = end.AddDate(0, 0, -7*(limit-1))
+	case TimeframeMonthly:
+		start = end.AddDate(0, -(limit - 1), 0)
+	case TimeframeQuarterly:
+		start = end.AddDate(0, -3*(limit-1), 0)
+	default:
+		start = end.AddDate(0, 0, -(limit - 1))
+	}
+
+	// Stepping back whole buckets from a date inside one can still leave a
+	// partial bucket too many
+	for ValidateDateRange(timeframe, start.Format("2006-01-02"), endDate) != nil {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start.Format("2006-01-02")
+}
+
+// ParseWeekday parses a weekday name such as "monday" or "Sun"
+func ParseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || (len(name) == 3 && strings.HasPrefix(full, name)) {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("unknown weekday %q", name)
+}
+
+// ConfigureWeekStart sets the day weekly buckets and cohorts start on when
+// a request does not choose one
+func (ds *DataService) ConfigureWeekStart(day time.Weekday) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.weekStart = day
+}
+
+// bucketer assigns times to calendar buckets in a time zone. Every
+// time-series endpoint and cohort assignment uses it so that bucket keys
+// and week boundaries are consistent across the API.
+type bucketer struct {
+	timeframe string
+	location  *time.Location
+	weekStart time.Weekday
+}
+
+// newBucketer creates a bucketer for the timeframe using the query's zone
+// and week start. Unknown timeframes bucket daily.
+func newBucketer(timeframe string, q queryOptions) bucketer {
+	if !IsValidTimeframe(timeframe) {
+		timeframe = TimeframeDaily
+	}
+	return bucketer{
+		timeframe: timeframe,
+		location:  q.location,
+		weekStart: q.weekStart,
+	}
+}
+
+// floor returns the start of the bucket containing t, in the bucketer's zone
+func (b bucketer) floor(t time.Time) time.Time {
+	t = t.In(b.location)
+	year, month, day := t.Date()
+
+	switch b.timeframe {
+	case TimeframeHourly:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, b.location)
+	case TimeframeWeekly:
+		offset := (int(t.Weekday()) - int(b.weekStart) + 7) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, b.location)
+	case TimeframeMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, b.location)
+	case TimeframeQuarterly:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, b.location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, b.location)
+	}
+}
+
+// next returns the start of the bucket following the one starting at start
+func (b bucketer) next(start time.Time) time.Time {
+	switch b.timeframe {
+	case TimeframeHourly:
+		return start.Add(time.Hour)
+	case TimeframeWeekly:
+		return start.AddDate(0, 0, 7)
+	case TimeframeMonthly:
+		return start.AddDate(0, 1, 0)
+	case TimeframeQuarterly:
+		return start.AddDate(0, 3, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// key formats the bucket containing t. Weekly buckets are keyed by the
+// date their week starts on.
+func (b bucketer) key(t time.Time) string {
+	start := b.floor(t)
+
+	switch b.timeframe {
+	case TimeframeHourly:
+		return start.Format("2006-01-02T15:00")
+	case TimeframeMonthly:
+		return start.Format("2006-01")
+	case TimeframeQuarterly:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// series returns the keys of every bucket overlapping [start, end), in
+// order, so responses can include buckets without events. It stops at the
+// timeframe's bucket limit; handlers reject wider ranges up front.
+func (b bucketer) series(start, end time.Time) []string {
+	var keys []string
+	limit := maxBuckets[b.timeframe]
+	for bucket := b.floor(start); bucket.Before(end) && len(keys) < limit; bucket = b.next(bucket) {
+		// A repeated wall-clock hour at a DST change maps to the same key
+		if key := b.key(bucket); len(keys) == 0 || keys[len(keys)-1] != key {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// finestWidth returns the rollup bucket width needed to serve this bucketer
+func (b bucketer) finestWidth() time.Duration {
+	if b.timeframe == TimeframeHourly {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
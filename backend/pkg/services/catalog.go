@@ -0,0 +1,229 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// catalogSampleSize is the number of sample contents kept per catalog entry
+const catalogSampleSize = 3
+
+// ErrUnknownCatalogEntry is returned when annotating a type/attribute pair
+// that does not occur in the data
+var ErrUnknownCatalogEntry = errors.New("unknown type/attribute pair")
+
+// catalogKey identifies a catalog entry
+type catalogKey struct {
+	eventType string
+	attribute string
+}
+
+// eventCatalog holds the editable catalog annotations and the file they are
+// persisted to. It has its own lock so edits do not block queries.
+type eventCatalog struct {
+	mu          sync.Mutex
+	path        string
+	annotations map[catalogKey]models.CatalogAnnotation
+}
+
+// newEventCatalog creates an in-memory catalog without persistence
+func newEventCatalog() *eventCatalog {
+	return &eventCatalog{
+		annotations: make(map[catalogKey]models.CatalogAnnotation),
+	}
+}
+
+// ConfigureCatalog persists catalog annotations to path, loading any that
+// were saved there before
+func (ds *DataService) ConfigureCatalog(path string) error {
+	annotations := make(map[catalogKey]models.CatalogAnnotation)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read event catalog: %w", err)
+	}
+	if err == nil {
+		var saved []models.CatalogAnnotation
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to parse event catalog %s: %w", path, err)
+		}
+		for _, annotation := range saved {
+			annotations[catalogKey{annotation.Type, annotation.Attribute}] = annotation
+		}
+	}
+
+	ds.catalog.mu.Lock()
+	defer ds.catalog.mu.Unlock()
+
+	ds.catalog.path = path
+	ds.catalog.annotations = annotations
+	return nil
+}
+
+// GetEventCatalog lists every type/attribute pair in the data with its
+// usage statistics and annotations, largest volume first
+func (ds *DataService) GetEventCatalog(opts ...QueryOption) models.CatalogResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	events := ds.selectEvents("", "", nil, ds.newQueryOptions(opts))
+	entries := make(map[catalogKey]*models.CatalogEntry)
+	users := make(map[catalogKey]map[string]bool)
+
+	// Walk newest first so samples are the most recent contents
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		key := catalogKey{event.Type, event.Attribute}
+
+		entry := entries[key]
+		if entry == nil {
+			entry = &models.CatalogEntry{
+				Type:          event.Type,
+				Attribute:     event.Attribute,
+				FirstSeen:     event.CreatedAt,
+				LastSeen:      event.CreatedAt,
+				SampleContent: []string{},
+			}
+			entries[key] = entry
+			users[key] = make(map[string]bool)
+		}
+
+		entry.Volume++
+		entry.FirstSeen = event.CreatedAt
+		if userID := ds.userID(event.User); userID != "" {
+			users[key][userID] = true
+		}
+		if len(entry.SampleContent) < catalogSampleSize && event.Content != "" && !containsString(entry.SampleContent, event.Content) {
+			entry.SampleContent = append(entry.SampleContent, event.Content)
+		}
+	}
+
+	ds.catalog.mu.Lock()
+	defer ds.catalog.mu.Unlock()
+
+	var catalog []models.CatalogEntry
+	for key, entry := range entries {
+		entry.DistinctUsers = len(users[key])
+		if annotation, exists := ds.catalog.annotations[key]; exists {
+			entry.Description = annotation.Description
+			entry.Owner = annotation.Owner
+		}
+		catalog = append(catalog, *entry)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Volume != catalog[j].Volume {
+			return catalog[i].Volume > catalog[j].Volume
+		}
+		if catalog[i].Type != catalog[j].Type {
+			return catalog[i].Type < catalog[j].Type
+		}
+		return catalog[i].Attribute < catalog[j].Attribute
+	})
+
+	return models.CatalogResponse{
+		Data:  catalog,
+		Total: len(catalog),
+	}
+}
+
+// AnnotateCatalogEntry sets the description and owner of a type/attribute
+// pair and persists the catalog
+func (ds *DataService) AnnotateCatalogEntry(annotation models.CatalogAnnotation) (models.CatalogAnnotation, error) {
+	if !ds.hasCatalogEntry(annotation.Type, annotation.Attribute) {
+		return models.CatalogAnnotation{}, ErrUnknownCatalogEntry
+	}
+
+	ds.catalog.mu.Lock()
+	defer ds.catalog.mu.Unlock()
+
+	annotation.UpdatedAt = time.Now().UTC()
+	key := catalogKey{annotation.Type, annotation.Attribute}
+	previous, existed := ds.catalog.annotations[key]
+	ds.catalog.annotations[key] = annotation
+
+	if err := ds.catalog.save(); err != nil {
+		// Keep memory consistent with what is on disk
+		if existed {
+			ds.catalog.annotations[key] = previous
+		} else {
+			delete(ds.catalog.annotations, key)
+		}
+		return models.CatalogAnnotation{}, err
+	}
+
+	return annotation, nil
+}
+
+// hasCatalogEntry reports whether any event has the type/attribute pair
+func (ds *DataService) hasCatalogEntry(eventType, attribute string) bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, event := range ds.events {
+		if event.Type == eventType && event.Attribute == attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the annotations to the catalog file; callers must hold c.mu
+func (c *eventCatalog) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	saved := make([]models.CatalogAnnotation, 0, len(c.annotations))
+	for _, annotation := range c.annotations {
+		saved = append(saved, annotation)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		if saved[i].Type != saved[j].Type {
+			return saved[i].Type < saved[j].Type
+		}
+		return saved[i].Attribute < saved[j].Attribute
+	})
+
+	return writeJSONFile(c.path, saved, "event catalog")
+}
+
+// writeJSONFile encodes v as indented JSON and replaces the file at path
+// atomically, so a failed write never truncates it. what names the file in
+// errors.
+func writeJSONFile(path string, v interface{}, what string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", what, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s directory: %w", what, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", what, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", what, err)
+	}
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// companyDetailLimit is the number of users and endpoints in a company view
+const companyDetailLimit = 10
+
+// GetCompanyName returns the display name of a company ID
+func (ds *DataService) GetCompanyName(companyID string) string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return companyNameFor(ds.companies, companyID)
+}
+
+// GetCompanyDetail returns a single company's trend, active users, top
+// endpoints, retention summary and last activity. Without a date range it
+// covers the company's whole history, clamped to the most recent buckets the
+// timeframe allows; the response reports the range actually used. ok is
+// false for an unknown company ID.
+//
+// The sections come from the same queries as the standalone endpoints, run
+// under one read lock so they describe the same snapshot of the data. They
+// are scoped to the company ID like a tenant query, since company names are
+// not unique. A tenant-scoped query only finds the tenant's own company.
+func (ds *DataService) GetCompanyDetail(companyID, timeframe, startDate, endDate string, opts ...QueryOption) (models.CompanyDetailResponse, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+	name, exists := ds.companies[companyID]
+	if !exists || !ds.loaded || (q.tenant != "" && q.tenant != companyID) {
+		return models.CompanyDetailResponse{}, false
+	}
+	name = companyNameFor(ds.companies, companyID)
+
+	// Default to the company's own first and last activity dates
+	postings := ds.tenantPositions(ds.index.companies[name], companyID)
+	if (startDate == "" || endDate == "") && len(postings) > 0 {
+		startDate = ds.events[postings[0]].CreatedAt.In(q.location).Format("2006-01-02")
+		endDate = ds.events[postings[len(postings)-1]].CreatedAt.In(q.location).Format("2006-01-02")
+		startDate = clampDateRange(timeframe, startDate, endDate)
+	}
+
+	var lastActivity *time.Time
+	if start, end, ok := parseDateRange(startDate, endDate, q.location); ok {
+		lo, hi := ds.dateBounds(start, end)
+		if inRange := positionsInRange(postings, lo, hi); len(inRange) > 0 {
+			last := ds.events[inRange[len(inRange)-1]].CreatedAt
+			lastActivity = &last
+		}
+	}
+
+	// Copy opts before adding the company scope so the caller's slice is
+	// left alone
+	q = ds.newQueryOptions(append(opts[:len(opts):len(opts)], ForTenant(companyID)))
+
+	response := models.CompanyDetailResponse{
+		CompanyID:    companyID,
+		CompanyName:  name,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		TotalEvents:  ds.metrics(startDate, endDate, nil, nil, q).TotalEvents,
+		ActiveUsers:  ds.uniqueUsersCount(startDate, endDate, nil, q),
+		LastActivity: lastActivity,
+		Trend:        ds.timeSeriesData(timeframe, startDate, endDate, nil, nil, q),
+		TopUsers:     ds.mostActiveUsers(startDate, endDate, nil, companyDetailLimit, q),
+		TopEndpoints: ds.topEndpointsByUsage(startDate, endDate, nil, companyDetailLimit, q),
+		Retention:    models.RetentionSummary{CohortPeriod: TimeframeWeekly},
+	}
+
+	retention, err := ds.retentionAnalytics(models.RetentionRequest{
+		StartDate:     startDate,
+		EndDate:       endDate,
+		CohortPeriod:  TimeframeWeekly,
+		MinCohortSize: 1,
+	}, q)
+	if err == nil && retention != nil {
+		response.Retention.TotalCohorts = retention.TotalCohorts
+		response.Retention.AverageRetention = retention.AverageRetention
+	}
+
+	return response, true
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// TestCompanyDetailSeparatesCompaniesSharingAName checks that two company
+// IDs whose content yields the same name are not merged in a drill-down
+func TestCompanyDetailSeparatesCompaniesSharingAName(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	var events []models.UsageEvent
+	add := func(companyID, user, endpoint string, count int) {
+		for i := 0; i < count; i++ {
+			events = append(events, models.UsageEvent{
+				ID:        fmt.Sprintf("event-%d", len(events)),
+				CreatedAt: base.AddDate(0, 0, len(events)),
+				CompanyID: companyID,
+				Type:      "Action",
+				Content:   "content without a company name " + user,
+				User:      user,
+				Endpoint:  endpoint,
+			})
+		}
+	}
+	add("cmp-first", "alice@first.com", "/first", 5)
+	add("cmp-second", "bob@second.com", "/second", 3)
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	if first, second := ds.GetCompanyName("cmp-first"), ds.GetCompanyName("cmp-second"); first != second {
+		t.Fatalf("fixture companies have names %q and %q, want a shared name", first, second)
+	}
+
+	detail, found := ds.GetCompanyDetail("cmp-first", TimeframeDaily, "", "")
+	if !found {
+		t.Fatal("GetCompanyDetail did not find cmp-first")
+	}
+	if detail.TotalEvents != 5 {
+		t.Errorf("TotalEvents = %d, want 5", detail.TotalEvents)
+	}
+	if detail.ActiveUsers != 1 {
+		t.Errorf("ActiveUsers = %d, want 1", detail.ActiveUsers)
+	}
+	if detail.EndDate != "2025-03-07" {
+		t.Errorf("EndDate = %s, want the first company's last day 2025-03-07", detail.EndDate)
+	}
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, marker := range []string{"bob@second.com", "/second", ds.userID("bob@second.com")} {
+		if strings.Contains(string(data), marker) {
+			t.Errorf("cmp-first drill-down includes %q from cmp-second: %s", marker, data)
+		}
+	}
+}
+
+// TestCompanyDetailClampsDefaultRange checks that a company history wider
+// than the timeframe's bucket limit is clamped to its most recent buckets
+func TestCompanyDetailClampsDefaultRange(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Date(2023, 1, 10, 9, 0, 0, 0, time.UTC)
+	events := []models.UsageEvent{
+		{ID: "old", CreatedAt: first, CompanyID: "cmp-test", Type: "Action", Content: "User active CMMS - Test ann@test.com /home"},
+		{ID: "new", CreatedAt: first.AddDate(2, 0, 0), CompanyID: "cmp-test", Type: "Action", Content: "User active CMMS - Test ann@test.com /home"},
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	detail, found := ds.GetCompanyDetail("cmp-test", TimeframeHourly, "", "")
+	if !found {
+		t.Fatal("GetCompanyDetail did not find cmp-test")
+	}
+	if detail.StartDate != "2024-01-11" || detail.EndDate != "2025-01-10" {
+		t.Errorf("range = %s to %s, want 2024-01-11 to 2025-01-10", detail.StartDate, detail.EndDate)
+	}
+	if err := ValidateDateRange(TimeframeHourly, detail.StartDate, detail.EndDate); err != nil {
+		t.Errorf("clamped range is invalid: %v", err)
+	}
+	if detail.TotalEvents != 1 {
+		t.Errorf("TotalEvents = %d, want only the event in the clamped range", detail.TotalEvents)
+	}
+	if len(detail.Trend.Data) != 366*24 {
+		t.Errorf("trend has %d points, want %d", len(detail.Trend.Data), 366*24)
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"analytics-dashboard/pkg/models"
@@ -16,25 +17,58 @@ import (
 
 // DataService handles data loading and operations
 type DataService struct {
+	mu         sync.RWMutex
 	dataPath   string
+	timezones  timezoneSettings
+	weekStart  time.Weekday
+	identities *identityResolver
+	features   []featureRule
 	events     []models.UsageEvent
 	companies  map[string]string
 	eventTypes map[string]int
+	index      *eventIndex
+	rollups    *rollups
+	catalog    *eventCatalog
+	segments   *segmentStore
 	loaded     bool
+	generation uint64 // Incremented whenever the dataset changes
+
+	// Load bookkeeping reported by GetDataStatus and Stats
+	skippedRows  int
+	loadedAt     time.Time
+	updatedAt    time.Time
+	loadDuration time.Duration
+	ingest       IngestStats
 }
 
 // NewDataService creates a new data service instance
 func NewDataService(dataPath string) (*DataService, error) {
+	identities, err := newIdentityResolver(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DataService{
 		dataPath:   dataPath,
+		weekStart:  time.Monday,
+		identities: identities,
 		companies:  make(map[string]string),
 		eventTypes: make(map[string]int),
+		index:      buildEventIndex(nil, nil, identities),
+		rollups:    newRollups(),
+		catalog:    newEventCatalog(),
+		segments:   newSegmentStore(),
 		loaded:     false,
 	}, nil
 }
 
 // LoadData loads CSV data into memory
 func (ds *DataService) LoadData() error {
+	started := time.Now()
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
 	if ds.loaded {
 		return nil
 	}
@@ -58,6 +92,7 @@ func (ds *DataService) LoadData() error {
 	var events []models.UsageEvent
 	companyMap := make(map[string]string)
 	eventTypeMap := make(map[string]int)
+	skipped := 0
 
 	for {
 		record, err := reader.Read()
@@ -65,19 +100,22 @@ func (ds *DataService) LoadData() error {
 			break
 		}
 		if err != nil {
-			log.Printf("Warning: failed to read CSV row: %v", err)
+			slog.Warn("Skipping unreadable CSV row", "error", err)
+			skipped++
 			continue
 		}
 
 		// Skip rows that don't have enough columns or have too many
 		if len(record) != 9 {
-			log.Printf("Warning: skipping row with %d columns (expected 9): %v", len(record), record)
+			slog.Warn("Skipping CSV row without 9 columns", "columns", len(record), "row", record)
+			skipped++
 			continue
 		}
 
 		event, err := ds.parseEvent(record)
 		if err != nil {
-			log.Printf("Warning: failed to parse event: %v", err)
+			slog.Warn("Skipping CSV row that is not an event", "error", err)
+			skipped++
 			continue
 		}
 
@@ -98,10 +136,17 @@ func (ds *DataService) LoadData() error {
 	ds.events = events
 	ds.companies = companyMap
 	ds.eventTypes = eventTypeMap
+	ds.index = buildEventIndex(events, companyMap, ds.identities)
+	ds.rollups = buildRollups(events, ds.identities)
 	ds.loaded = true
+	ds.generation++
+	ds.skippedRows = skipped
+	ds.loadedAt = time.Now().UTC()
+	ds.updatedAt = ds.loadedAt
+	ds.loadDuration = time.Since(started)
 
-	log.Printf("Loaded %d events, %d companies, %d event types",
-		len(events), len(companyMap), len(eventTypeMap))
+	slog.Info("Loaded events", "events", len(events), "companies", len(companyMap),
+		"eventTypes", len(eventTypeMap), "skippedRows", skipped)
 
 	return nil
 }
@@ -219,34 +264,148 @@ func (ds *DataService) extractUserAndEndpoint(content string) (string, string) {
 
 // GetTotalEvents returns the total number of events
 func (ds *DataService) GetTotalEvents() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	return len(ds.events)
 }
 
+// Generation returns a counter that changes whenever the dataset changes
+func (ds *DataService) Generation() uint64 {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.generation
+}
+
+// Ready reports whether any events are loaded, so queries have data to
+// answer from
+func (ds *DataService) Ready() bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.loaded && len(ds.events) > 0
+}
+
+// Stats summarizes the dataset and its loading for monitoring
+type Stats struct {
+	Events       int
+	Companies    int
+	Users        int // Distinct resolved identities
+	EventTypes   int
+	SkippedRows  int
+	LoadDuration time.Duration // Time the last LoadData call took
+	Ingest       IngestStats
+}
+
+// IngestStats are running totals of ingestion flushes since startup
+type IngestStats struct {
+	Batches  uint64
+	Events   uint64
+	Duration time.Duration // Total time spent applying batches
+}
+
+// Stats returns the dataset sizes and load and ingestion totals
+func (ds *DataService) Stats() Stats {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return Stats{
+		Events:       len(ds.events),
+		Companies:    len(ds.companies),
+		Users:        len(ds.index.users),
+		EventTypes:   len(ds.eventTypes),
+		SkippedRows:  ds.skippedRows,
+		LoadDuration: ds.loadDuration,
+		Ingest:       ds.ingest,
+	}
+}
+
+// GetDataStatus describes the loaded dataset: its source, size, the time
+// range its events cover and when it was loaded and last changed. Counts
+// and the time range cover only the tenant's events when scoped to one.
+func (ds *DataService) GetDataStatus(opts ...QueryOption) models.DataStatus {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	status := models.DataStatus{
+		Source:      ds.dataPath,
+		SkippedRows: ds.skippedRows,
+		Generation:  ds.generation,
+	}
+	if !ds.loadedAt.IsZero() {
+		loadedAt := ds.loadedAt
+		status.LoadedAt = &loadedAt
+	}
+	if !ds.updatedAt.IsZero() {
+		updatedAt := ds.updatedAt
+		status.UpdatedAt = &updatedAt
+	}
+	if !ds.loaded {
+		return status
+	}
+
+	q := ds.newQueryOptions(opts)
+	events := ds.selectEvents("", "", nil, q)
+	if q.tenant == "" {
+		status.Companies = len(ds.companies)
+		status.EventTypes = len(ds.eventTypes)
+	} else {
+		companies := make(map[string]bool)
+		eventTypes := make(map[string]bool)
+		for _, event := range events {
+			companies[event.CompanyID] = true
+			eventTypes[event.Type] = true
+		}
+		status.Companies = len(companies)
+		status.EventTypes = len(eventTypes)
+	}
+
+	// Events are kept sorted by creation time
+	status.TotalEvents = len(events)
+	status.Ready = len(events) > 0
+	if len(events) > 0 {
+		earliest, latest := events[0].CreatedAt, events[len(events)-1].CreatedAt
+		status.EarliestEvent, status.LatestEvent = &earliest, &latest
+	}
+	return status
+}
+
 // GetAllEvents returns all events with pagination
-func (ds *DataService) GetAllEvents(page, pageSize int) ([]models.UsageEvent, int) {
+func (ds *DataService) GetAllEvents(page, pageSize int, opts ...QueryOption) ([]models.UsageEvent, int) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if !ds.loaded {
 		return []models.UsageEvent{}, 0
 	}
 
+	events := ds.selectEvents("", "", nil, ds.newQueryOptions(opts))
+
 	// Apply pagination
 	start := (page - 1) * pageSize
 	end := start + pageSize
 
-	if start >= len(ds.events) {
-		return []models.UsageEvent{}, len(ds.events)
+	if start >= len(events) {
+		return []models.UsageEvent{}, len(events)
 	}
 
-	if end > len(ds.events) {
-		end = len(ds.events)
+	if end > len(events) {
+		end = len(events)
 	}
 
-	return ds.events[start:end], len(ds.events)
+	return events[start:end], len(events)
 }
 
 // SearchEvents performs search and filtering on events
-func (ds *DataService) SearchEvents(req models.SearchRequest) models.SearchResponse {
+func (ds *DataService) SearchEvents(req models.SearchRequest, opts ...QueryOption) models.SearchResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
 	if !ds.loaded {
-		log.Printf("Data not loaded, returning empty response")
+		slog.Debug("Data not loaded, returning empty response")
 		return models.SearchResponse{
 			Data:         []models.UsageEvent{},
 			Pagination:   models.PaginationInfo{},
@@ -254,16 +413,21 @@ func (ds *DataService) SearchEvents(req models.SearchRequest) models.SearchRespo
 		}
 	}
 
-	log.Printf("SearchEvents: Starting with %d total events", len(ds.events))
+	slog.Debug("SearchEvents: starting", "events", len(ds.events))
 
-	// Apply filters
-	filtered := ds.applyFilters(ds.events, req.Filters)
-	log.Printf("SearchEvents: After filtering: %d events", len(filtered))
+	// Select the date range and companies through the index, then apply
+	// the remaining filters
+	var startDate, endDate string
+	if req.Filters.DateRange != nil {
+		startDate, endDate = req.Filters.DateRange.Start, req.Filters.DateRange.End
+	}
+	filtered := ds.applyFilters(ds.selectEvents(startDate, endDate, req.Filters.Companies, q), req.Filters)
+	slog.Debug("SearchEvents: after filtering", "events", len(filtered))
 
 	// Apply search query
 	if req.SearchQuery != "" {
-		filtered = ds.applySearch(filtered, req.SearchQuery)
-		log.Printf("SearchEvents: After search: %d events", len(filtered))
+		filtered = ds.applySearch(filtered, req.SearchQuery, q.noPII)
+		slog.Debug("SearchEvents: after search", "events", len(filtered))
 	}
 
 	// Apply pagination
@@ -287,7 +451,7 @@ func (ds *DataService) SearchEvents(req models.SearchRequest) models.SearchRespo
 		paginated = filtered[start:end]
 	}
 
-	log.Printf("SearchEvents: After pagination: %d events (page %d, size %d)", len(paginated), page, pageSize)
+	slog.Debug("SearchEvents: after pagination", "events", len(paginated), "page", page, "pageSize", pageSize)
 
 	// Enhance events with company names and user information
 	enhancedEvents := ds.enhanceEvents(paginated)
@@ -312,7 +476,7 @@ func (ds *DataService) SearchEvents(req models.SearchRequest) models.SearchRespo
 
 // enhanceEvents adds company names and user information to events
 func (ds *DataService) enhanceEvents(events []models.UsageEvent) []models.UsageEvent {
-	log.Printf("Enhancing %d events", len(events))
+	slog.Debug("Enhancing events", "events", len(events))
 
 	enhanced := make([]models.UsageEvent, len(events))
 
@@ -335,7 +499,7 @@ func (ds *DataService) enhanceEvents(events []models.UsageEvent) []models.UsageE
 		enhanced[i].Endpoint = endpoint
 	}
 
-	log.Printf("Enhanced %d events successfully", len(enhanced))
+	slog.Debug("Enhanced events", "events", len(enhanced))
 	return enhanced
 }
 
@@ -419,50 +583,12 @@ func (ds *DataService) extractEndpointFromContent(content string) string {
 	return "N/A"
 }
 
-// applyFilters applies filters to events
+// applyFilters applies the filters that selectEvents does not cover
 func (ds *DataService) applyFilters(events []models.UsageEvent, filters models.SearchFilters) []models.UsageEvent {
-	log.Printf("applyFilters: Starting with %d events", len(events))
-
-	if filters.DateRange != nil {
-		log.Printf("applyFilters: Applying date range filter: %s to %s", filters.DateRange.Start, filters.DateRange.End)
-		startDate, _ := time.Parse("2006-01-02", filters.DateRange.Start)
-		endDate, _ := time.Parse("2006-01-02", filters.DateRange.End)
-		endDate = endDate.Add(24 * time.Hour) // Include the entire end date
-
-		var filtered []models.UsageEvent
-		for _, event := range events {
-			if event.CreatedAt.After(startDate) && event.CreatedAt.Before(endDate) {
-				filtered = append(filtered, event)
-			}
-		}
-		events = filtered
-		log.Printf("applyFilters: After date filter: %d events", len(events))
-	}
-
-	if len(filters.Companies) > 0 {
-		log.Printf("applyFilters: Applying company filter: %v", filters.Companies)
-		companySet := make(map[string]bool)
-		for _, company := range filters.Companies {
-			companySet[company] = true
-		}
-
-		var filtered []models.UsageEvent
-		for _, event := range events {
-			// Get company name from the companies map
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				filtered = append(filtered, event)
-			}
-		}
-		events = filtered
-		log.Printf("applyFilters: After company filter: %d events", len(events))
-	}
+	slog.Debug("applyFilters: starting", "events", len(events))
 
 	if len(filters.EventTypes) > 0 {
-		log.Printf("applyFilters: Applying event type filter: %v", filters.EventTypes)
+		slog.Debug("applyFilters: applying event type filter", "eventTypes", filters.EventTypes)
 		eventTypeSet := make(map[string]bool)
 		for _, eventType := range filters.EventTypes {
 			eventTypeSet[eventType] = true
@@ -475,15 +601,16 @@ func (ds *DataService) applyFilters(events []models.UsageEvent, filters models.S
 			}
 		}
 		events = filtered
-		log.Printf("applyFilters: After event type filter: %d events", len(events))
+		slog.Debug("applyFilters: after event type filter", "events", len(events))
 	}
 
-	log.Printf("applyFilters: Final result: %d events", len(events))
+	slog.Debug("applyFilters: done", "events", len(events))
 	return events
 }
 
-// applySearch applies search query to events
-func (ds *DataService) applySearch(events []models.UsageEvent, query string) []models.UsageEvent {
+// applySearch applies search query to events. With noPII set the query
+// is not matched against the user or the raw content.
+func (ds *DataService) applySearch(events []models.UsageEvent, query string, noPII bool) []models.UsageEvent {
 	query = strings.ToLower(query)
 	var filtered []models.UsageEvent
 
@@ -494,11 +621,12 @@ func (ds *DataService) applySearch(events []models.UsageEvent, query string) []m
 			companyName = "Unknown Company"
 		}
 
-		if strings.Contains(strings.ToLower(event.Content), query) ||
+		personal := !noPII && (strings.Contains(strings.ToLower(event.Content), query) ||
+			strings.Contains(strings.ToLower(event.User), query))
+		if personal ||
 			strings.Contains(strings.ToLower(event.Attribute), query) ||
 			strings.Contains(strings.ToLower(event.Type), query) ||
 			strings.Contains(strings.ToLower(companyName), query) ||
-			strings.Contains(strings.ToLower(event.User), query) ||
 			strings.Contains(strings.ToLower(event.Endpoint), query) {
 			filtered = append(filtered, event)
 		}
@@ -538,65 +666,51 @@ func (ds *DataService) calculateAggregations(events []models.UsageEvent) models.
 }
 
 // GetUniqueUsersCount returns the count of unique users based on filters
-func (ds *DataService) GetUniqueUsersCount(startDate, endDate string, companies []string) int {
+func (ds *DataService) GetUniqueUsersCount(startDate, endDate string, companies []string, opts ...QueryOption) int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.uniqueUsersCount(startDate, endDate, companies, ds.newQueryOptions(opts))
+}
+
+// uniqueUsersCount is GetUniqueUsersCount without locking; callers hold ds.mu
+func (ds *DataService) uniqueUsersCount(startDate, endDate string, companies []string, q queryOptions) int {
+
 	if !ds.loaded {
 		return 0
 	}
 
 	// Filter events
-	filtered := ds.events
-
-	// Apply date range filter
-	if startDate != "" && endDate != "" {
-		start, _ := time.Parse("2006-01-02", startDate)
-		end, _ := time.Parse("2006-01-02", endDate)
-		end = end.Add(24 * time.Hour)
-
-		var dateFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if event.CreatedAt.After(start) && event.CreatedAt.Before(end) {
-				dateFiltered = append(dateFiltered, event)
-			}
-		}
-		filtered = dateFiltered
-	}
-
-	// Apply company filter
-	if len(companies) > 0 {
-		companySet := make(map[string]bool)
-		for _, company := range companies {
-			companySet[company] = true
-		}
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			// Get company name from the companies map
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				companyFiltered = append(companyFiltered, event)
-			}
-		}
-		filtered = companyFiltered
-	}
+	filtered := ds.selectEvents(startDate, endDate, companies, q)
 
-	// Count unique users
+	// Count unique users by resolved identity
 	userSet := make(map[string]bool)
 	for _, event := range filtered {
-		if event.User != "" && event.User != "Unknown User" {
-			userSet[event.User] = true
+		if userID := ds.userID(event.User); userID != "" {
+			userSet[userID] = true
 		}
 	}
 
 	return len(userSet)
 }
 
-// GetAllCompanyNames returns all company names
-func (ds *DataService) GetAllCompanyNames() []string {
+// GetAllCompanyNames returns all company names visible to the query
+func (ds *DataService) GetAllCompanyNames(opts ...QueryOption) []string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.allCompanyNames(ds.newQueryOptions(opts))
+}
+
+// allCompanyNames returns all company names, or only the tenant's company
+// when the query is scoped to one; callers must hold ds.mu
+func (ds *DataService) allCompanyNames(q queryOptions) []string {
 	if !ds.loaded {
 		return []string{}
 	}
+	if q.tenant != "" {
+		return []string{companyNameFor(ds.companies, q.tenant)}
+	}
 
 	companyNames := make([]string, 0, len(ds.companies))
 	for _, name := range ds.companies {
@@ -608,7 +722,12 @@ func (ds *DataService) GetAllCompanyNames() []string {
 }
 
 // GetMultiCompanyTimeSeriesData returns time series data for multiple companies
-func (ds *DataService) GetMultiCompanyTimeSeriesData(timeframe, startDate, endDate string, companies, eventTypes []string) models.MultiCompanyTimeSeriesResponse {
+func (ds *DataService) GetMultiCompanyTimeSeriesData(timeframe, startDate, endDate string, companies, eventTypes []string, opts ...QueryOption) models.MultiCompanyTimeSeriesResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
 	if !ds.loaded {
 		return models.MultiCompanyTimeSeriesResponse{
 			Data:        []map[string]interface{}{},
@@ -618,125 +737,72 @@ func (ds *DataService) GetMultiCompanyTimeSeriesData(timeframe, startDate, endDa
 	}
 
 	// Parse dates
-	start, _ := time.Parse("2006-01-02", startDate)
-	end, _ := time.Parse("2006-01-02", endDate)
-	end = end.Add(24 * time.Hour)
-
-	// Filter events
-	filtered := ds.events
-
-	// Apply company filter
-	if len(companies) > 0 {
-		companySet := make(map[string]bool)
-		for _, company := range companies {
-			companySet[company] = true
-		}
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			// Get company name from the companies map
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				companyFiltered = append(companyFiltered, event)
-			}
-		}
-		filtered = companyFiltered
-	}
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	b := newBucketer(timeframe, q)
 
-	// Apply event type filter
-	if len(eventTypes) > 0 {
-		eventTypeSet := make(map[string]bool)
-		for _, eventType := range eventTypes {
-			eventTypeSet[eventType] = true
-		}
-		var eventTypeFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if eventTypeSet[event.Type] {
-				eventTypeFiltered = append(eventTypeFiltered, event)
-			}
-		}
-		filtered = eventTypeFiltered
+	// Group events by date and company, preferring the rollup tables
+	dateCompanyMap, ok := ds.multiCompanySeriesFromRollups(b, start, end, companies, eventTypes, q)
+	if !ok {
+		dateCompanyMap = ds.multiCompanySeriesFromEvents(b, startDate, endDate, companies, eventTypes, q)
 	}
 
-	// Group events by date and company
-	dateCompanyMap := make(map[string]map[string]int)
-
-	for _, event := range filtered {
-		if event.CreatedAt.Before(start) || event.CreatedAt.After(end) {
-			continue
-		}
-
-		// Format date based on timeframe
-		var dateKey string
-		switch timeframe {
-		case "daily":
-			dateKey = event.CreatedAt.Format("2006-01-02")
-		case "weekly":
-			// Get the start of the week (Monday)
-			weekStart := event.CreatedAt
-			for weekStart.Weekday() != time.Monday {
-				weekStart = weekStart.AddDate(0, 0, -1)
-			}
-			dateKey = weekStart.Format("2006-01-02")
-		case "monthly":
-			dateKey = event.CreatedAt.Format("2006-01")
-		default:
-			dateKey = event.CreatedAt.Format("2006-01-02")
-		}
-
-		// Get company name
-		companyName := ds.companies[event.CompanyID]
-		if companyName == "" {
-			companyName = "Unknown Company"
-		}
-
-		if dateCompanyMap[dateKey] == nil {
-			dateCompanyMap[dateKey] = make(map[string]int)
-		}
-		dateCompanyMap[dateKey][companyName]++
-	}
-
-	// Ensure all companies are included in the response (with 0 values if no events)
-	allCompanyNames := ds.GetAllCompanyNames()
-	for dateKey := range dateCompanyMap {
-		for _, companyName := range allCompanyNames {
-			if dateCompanyMap[dateKey][companyName] == 0 {
-				dateCompanyMap[dateKey][companyName] = 0
-			}
-		}
-	}
-
-	// Convert to response format with company-specific data
+	// Emit every bucket in the range, with all companies included (0 if no events)
+	allCompanyNames := ds.allCompanyNames(q)
 	var data []map[string]interface{}
-	for dateKey, companyCounts := range dateCompanyMap {
+	for _, dateKey := range b.series(start, end) {
 		dataPoint := map[string]interface{}{
 			"timestamp": dateKey,
 		}
 
+		for _, companyName := range allCompanyNames {
+			dataPoint[companyName] = 0
+		}
+
 		// Add company-specific data
-		for companyName, count := range companyCounts {
+		for companyName, count := range dateCompanyMap[dateKey] {
 			dataPoint[companyName] = count
 		}
 
 		data = append(data, dataPoint)
 	}
 
-	// Sort by timestamp
-	sort.Slice(data, func(i, j int) bool {
-		return data[i]["timestamp"].(string) < data[j]["timestamp"].(string)
-	})
-
 	return models.MultiCompanyTimeSeriesResponse{
 		Data:        data,
-		Timeframe:   timeframe,
+		Timeframe:   b.timeframe,
+		Timezone:    q.location.String(),
 		TotalPoints: len(data),
 	}
 }
 
+// multiCompanySeriesFromEvents groups raw events by bucket and company
+func (ds *DataService) multiCompanySeriesFromEvents(b bucketer, startDate, endDate string, companies, eventTypes []string, q queryOptions) map[string]map[string]int {
+	filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
+
+	dateCompanyMap := make(map[string]map[string]int)
+	for _, event := range filtered {
+		dateKey := b.key(event.CreatedAt)
+		companyName := companyNameFor(ds.companies, event.CompanyID)
+
+		if dateCompanyMap[dateKey] == nil {
+			dateCompanyMap[dateKey] = make(map[string]int)
+		}
+		dateCompanyMap[dateKey][companyName]++
+	}
+
+	return dateCompanyMap
+}
+
 // GetTimeSeriesData returns time series data for trends
-func (ds *DataService) GetTimeSeriesData(timeframe, startDate, endDate string, companies, eventTypes []string) models.TimeSeriesResponse {
+func (ds *DataService) GetTimeSeriesData(timeframe, startDate, endDate string, companies, eventTypes []string, opts ...QueryOption) models.TimeSeriesResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.timeSeriesData(timeframe, startDate, endDate, companies, eventTypes, ds.newQueryOptions(opts))
+}
+
+// timeSeriesData is GetTimeSeriesData without locking; callers hold ds.mu
+func (ds *DataService) timeSeriesData(timeframe, startDate, endDate string, companies, eventTypes []string, q queryOptions) models.TimeSeriesResponse {
+
 	if !ds.loaded {
 		return models.TimeSeriesResponse{
 			Data:        []models.TimeSeriesData{},
@@ -746,88 +812,76 @@ func (ds *DataService) GetTimeSeriesData(timeframe, startDate, endDate string, c
 	}
 
 	// Parse dates
-	start, _ := time.Parse("2006-01-02", startDate)
-	end, _ := time.Parse("2006-01-02", endDate)
-	end = end.Add(24 * time.Hour)
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	b := newBucketer(timeframe, q)
 
-	// Filter events
-	filtered := ds.events
-	if len(companies) > 0 {
-		companySet := make(map[string]bool)
-		for _, company := range companies {
-			companySet[company] = true
-		}
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			// Get company name from the companies map
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				companyFiltered = append(companyFiltered, event)
-			}
-		}
-		filtered = companyFiltered
+	// Group by timeframe, preferring the rollup tables
+	timeSeriesMap, ok := ds.timeSeriesFromRollups(b, start, end, companies, eventTypes, q)
+	if !ok {
+		timeSeriesMap = ds.timeSeriesFromEvents(b, startDate, endDate, companies, eventTypes, q)
 	}
 
-	if len(eventTypes) > 0 {
-		eventTypeSet := make(map[string]bool)
-		for _, eventType := range eventTypes {
-			eventTypeSet[eventType] = true
-		}
-		var eventTypeFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if eventTypeSet[event.Type] {
-				eventTypeFiltered = append(eventTypeFiltered, event)
-			}
-		}
-		filtered = eventTypeFiltered
-	}
-
-	// Group by timeframe
-	timeSeriesMap := make(map[string]int)
-	for _, event := range filtered {
-		if event.CreatedAt.After(start) && event.CreatedAt.Before(end) {
-			var key string
-			switch timeframe {
-			case "daily":
-				key = event.CreatedAt.Format("2006-01-02")
-			case "weekly":
-				year, week := event.CreatedAt.ISOWeek()
-				key = fmt.Sprintf("%d-W%02d", year, week)
-			case "monthly":
-				key = event.CreatedAt.Format("2006-01")
-			default:
-				key = event.CreatedAt.Format("2006-01-02")
-			}
-			timeSeriesMap[key]++
-		}
-	}
-
-	// Convert to slice and sort
+	// Emit every bucket in the range, including empty ones
 	var data []models.TimeSeriesData
-	for timestamp, value := range timeSeriesMap {
+	for _, timestamp := range b.series(start, end) {
 		data = append(data, models.TimeSeriesData{
 			Timestamp: timestamp,
-			Value:     value,
-			EventType: "Action", // Default event type
+			Value:     pointValue(float64(timeSeriesMap[timestamp])),
+			EventType: eventTypeLabel(eventTypes),
 		})
 	}
 
-	sort.Slice(data, func(i, j int) bool {
-		return data[i].Timestamp < data[j].Timestamp
-	})
-
 	return models.TimeSeriesResponse{
 		Data:        data,
-		Timeframe:   timeframe,
+		Timeframe:   b.timeframe,
+		Timezone:    q.location.String(),
 		TotalPoints: len(data),
 	}
 }
 
+// timeSeriesFromEvents groups raw events by bucket
+func (ds *DataService) timeSeriesFromEvents(b bucketer, startDate, endDate string, companies, eventTypes []string, q queryOptions) map[string]int {
+	filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
+
+	timeSeriesMap := make(map[string]int)
+	for _, event := range filtered {
+		timeSeriesMap[b.key(event.CreatedAt)]++
+	}
+
+	return timeSeriesMap
+}
+
+// filterByEventTypes keeps only events of the given types
+func (ds *DataService) filterByEventTypes(events []models.UsageEvent, eventTypes []string) []models.UsageEvent {
+	if len(eventTypes) == 0 {
+		return events
+	}
+
+	eventTypeSet := make(map[string]bool)
+	for _, eventType := range eventTypes {
+		eventTypeSet[eventType] = true
+	}
+
+	var filtered []models.UsageEvent
+	for _, event := range events {
+		if eventTypeSet[event.Type] {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
 // GetMetrics returns aggregated metrics
-func (ds *DataService) GetMetrics(startDate, endDate string, companies, eventTypes []string) models.MetricsResponse {
+func (ds *DataService) GetMetrics(startDate, endDate string, companies, eventTypes []string, opts ...QueryOption) models.MetricsResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.metrics(startDate, endDate, companies, eventTypes, ds.newQueryOptions(opts))
+}
+
+// metrics is GetMetrics without locking; callers hold ds.mu
+func (ds *DataService) metrics(startDate, endDate string, companies, eventTypes []string, q queryOptions) models.MetricsResponse {
+
 	if !ds.loaded {
 		return models.MetricsResponse{
 			TotalEvents:     0,
@@ -837,54 +891,19 @@ func (ds *DataService) GetMetrics(startDate, endDate string, companies, eventTyp
 		}
 	}
 
-	// Filter events
-	filtered := ds.events
-	if startDate != "" && endDate != "" {
-		start, _ := time.Parse("2006-01-02", startDate)
-		end, _ := time.Parse("2006-01-02", endDate)
-		end = end.Add(24 * time.Hour)
-
-		var dateFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if event.CreatedAt.After(start) && event.CreatedAt.Before(end) {
-				dateFiltered = append(dateFiltered, event)
-			}
-		}
-		filtered = dateFiltered
-	}
-
-	if len(companies) > 0 {
-		companySet := make(map[string]bool)
-		for _, company := range companies {
-			companySet[company] = true
-		}
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			// Get company name from the companies map
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				companyFiltered = append(companyFiltered, event)
-			}
+	// Serve from the rollup tables when the range lines up with their buckets
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	if metrics, ok := ds.metricsFromRollups(start, end, companies, eventTypes, q); ok {
+		if q.valueStats {
+			// Values are not rolled up, so summarize them from the raw events
+			filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
+			metrics.ValueStats = valueStatsFor(valueSamplesBy(filtered, allValues), "")
 		}
-		filtered = companyFiltered
+		return metrics
 	}
 
-	if len(eventTypes) > 0 {
-		eventTypeSet := make(map[string]bool)
-		for _, eventType := range eventTypes {
-			eventTypeSet[eventType] = true
-		}
-		var eventTypeFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if eventTypeSet[event.Type] {
-				eventTypeFiltered = append(eventTypeFiltered, event)
-			}
-		}
-		filtered = eventTypeFiltered
-	}
+	// Filter events
+	filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
 
 	// Calculate metrics
 	companySet := make(map[string]bool)
@@ -915,16 +934,24 @@ func (ds *DataService) GetMetrics(startDate, endDate string, companies, eventTyp
 		timeRange.End = filtered[len(filtered)-1].CreatedAt.Format("2006-01-02T15:04:05Z")
 	}
 
-	return models.MetricsResponse{
+	response := models.MetricsResponse{
 		TotalEvents:     len(filtered),
 		ActiveCompanies: len(companySet),
 		TopEventTypes:   topEventTypes,
 		TimeRange:       timeRange,
 	}
+	if q.valueStats {
+		response.ValueStats = valueStatsFor(valueSamplesBy(filtered, allValues), "")
+	}
+
+	return response
 }
 
 // GetCompanies returns all companies
-func (ds *DataService) GetCompanies() models.CompaniesResponse {
+func (ds *DataService) GetCompanies(opts ...QueryOption) models.CompaniesResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if !ds.loaded {
 		return models.CompaniesResponse{
 			Data:  []models.Company{},
@@ -936,7 +963,7 @@ func (ds *DataService) GetCompanies() models.CompaniesResponse {
 	companyEventCounts := make(map[string]int)
 
 	// Count events per company
-	for _, event := range ds.events {
+	for _, event := range ds.selectEvents("", "", nil, ds.newQueryOptions(opts)) {
 		companyEventCounts[event.CompanyID]++
 	}
 
@@ -965,7 +992,10 @@ func (ds *DataService) GetCompanies() models.CompaniesResponse {
 }
 
 // GetTopActiveCompanies returns top 5 most active companies
-func (ds *DataService) GetTopActiveCompanies() models.CompanyAnalyticsResponse {
+func (ds *DataService) GetTopActiveCompanies(opts ...QueryOption) models.CompanyAnalyticsResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if !ds.loaded {
 		return models.CompanyAnalyticsResponse{
 			Data:  []models.CompanyAnalytics{},
@@ -979,7 +1009,8 @@ func (ds *DataService) GetTopActiveCompanies() models.CompanyAnalyticsResponse {
 	})
 
 	// Calculate company statistics
-	for _, event := range ds.events {
+	events := ds.selectEvents("", "", nil, ds.newQueryOptions(opts))
+	for _, event := range events {
 		stats := companyStats[event.CompanyID]
 		stats.eventCount++
 		if event.CreatedAt.After(stats.lastActivity) {
@@ -990,7 +1021,7 @@ func (ds *DataService) GetTopActiveCompanies() models.CompanyAnalyticsResponse {
 
 	// Convert to slice
 	var companies []models.CompanyAnalytics
-	totalEvents := len(ds.events)
+	totalEvents := len(events)
 
 	for companyID, stats := range companyStats {
 		name := ds.companies[companyID]
@@ -1024,7 +1055,10 @@ func (ds *DataService) GetTopActiveCompanies() models.CompanyAnalyticsResponse {
 }
 
 // GetEventDistribution returns event distribution by type
-func (ds *DataService) GetEventDistribution() models.EventDistributionResponse {
+func (ds *DataService) GetEventDistribution(opts ...QueryOption) models.EventDistributionResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if !ds.loaded {
 		return models.EventDistributionResponse{
 			Data:  []models.EventDistribution{},
@@ -1032,25 +1066,29 @@ func (ds *DataService) GetEventDistribution() models.EventDistributionResponse {
 		}
 	}
 
-	var distributions []models.EventDistribution
-	totalEvents := len(ds.events)
-
-	for eventType, count := range ds.eventTypes {
-		percentage := float64(count) / float64(totalEvents) * 100
+	events := ds.selectEvents("", "", nil, ds.newQueryOptions(opts))
+	totalEvents := len(events)
 
-		// Count unique companies for this event type
-		companySet := make(map[string]bool)
-		for _, event := range ds.events {
-			if event.Type == eventType {
-				companySet[event.CompanyID] = true
-			}
+	// Count events and unique companies per event type
+	typeCounts := make(map[string]int)
+	typeCompanies := make(map[string]map[string]bool)
+	for _, event := range events {
+		typeCounts[event.Type]++
+		if typeCompanies[event.Type] == nil {
+			typeCompanies[event.Type] = make(map[string]bool)
 		}
+		typeCompanies[event.Type][event.CompanyID] = true
+	}
+
+	var distributions []models.EventDistribution
+	for eventType, count := range typeCounts {
+		percentage := float64(count) / float64(totalEvents) * 100
 
 		distributions = append(distributions, models.EventDistribution{
 			Type:       eventType,
 			Count:      count,
 			Percentage: percentage,
-			Companies:  len(companySet),
+			Companies:  len(typeCompanies[eventType]),
 		})
 	}
 
@@ -1066,13 +1104,18 @@ func (ds *DataService) GetEventDistribution() models.EventDistributionResponse {
 }
 
 // GetTopEventsByVolume returns top events by volume with filtering support
-func (ds *DataService) GetTopEventsByVolume(startDate, endDate string, companies []string, limit int) []models.EventTypeCount {
+func (ds *DataService) GetTopEventsByVolume(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.EventTypeCount {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
 	if !ds.loaded {
 		return []models.EventTypeCount{}
 	}
 
 	// Filter events
-	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies)
+	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies, q)
 
 	// Count events by type
 	eventTypeCounts := make(map[string]int)
@@ -1099,67 +1142,89 @@ func (ds *DataService) GetTopEventsByVolume(startDate, endDate string, companies
 		topEvents = topEvents[:limit]
 	}
 
+	if q.valueStats {
+		samples := valueSamplesBy(filtered, func(event models.UsageEvent) string { return event.Type })
+		for i := range topEvents {
+			topEvents[i].ValueStats = valueStatsFor(samples, topEvents[i].Type)
+		}
+	}
+
 	return topEvents
 }
 
 // GetMostActiveUsers returns most active users with filtering support
-func (ds *DataService) GetMostActiveUsers(startDate, endDate string, companies []string, limit int) []models.UserActivity {
+func (ds *DataService) GetMostActiveUsers(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.UserActivity {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.mostActiveUsers(startDate, endDate, companies, limit, ds.newQueryOptions(opts))
+}
+
+// mostActiveUsers is GetMostActiveUsers without locking; callers hold ds.mu
+func (ds *DataService) mostActiveUsers(startDate, endDate string, companies []string, limit int, q queryOptions) []models.UserActivity {
+
 	if !ds.loaded {
 		return []models.UserActivity{}
 	}
 
 	// Filter events
-	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies)
+	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies, q)
 
-	// Count events by user
+	// Count events by resolved user identity
 	userCounts := make(map[string]int)
 	userCompanies := make(map[string]map[string]bool)
 	userCompanyNames := make(map[string]map[string]bool)
 	userLastActivity := make(map[string]time.Time)
+	userEmails := make(map[string]string)
 
 	for _, event := range filtered {
-		if event.User != "" {
-			userCounts[event.User]++
+		identity := ds.identities.resolve(event.User)
+		userID := identity.id
+		if userID == "" {
+			continue
+		}
+		userCounts[userID]++
+		userEmails[userID] = identity.email
 
-			// Track unique companies per user
-			if userCompanies[event.User] == nil {
-				userCompanies[event.User] = make(map[string]bool)
-			}
-			userCompanies[event.User][event.CompanyID] = true
+		// Track unique companies per user
+		if userCompanies[userID] == nil {
+			userCompanies[userID] = make(map[string]bool)
+		}
+		userCompanies[userID][event.CompanyID] = true
 
-			// Track unique company names per user
-			if userCompanyNames[event.User] == nil {
-				userCompanyNames[event.User] = make(map[string]bool)
-			}
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			userCompanyNames[event.User][companyName] = true
+		// Track unique company names per user
+		if userCompanyNames[userID] == nil {
+			userCompanyNames[userID] = make(map[string]bool)
+		}
+		companyName := ds.companies[event.CompanyID]
+		if companyName == "" {
+			companyName = "Unknown Company"
+		}
+		userCompanyNames[userID][companyName] = true
 
-			// Track last activity
-			if event.CreatedAt.After(userLastActivity[event.User]) {
-				userLastActivity[event.User] = event.CreatedAt
-			}
+		// Track last activity
+		if event.CreatedAt.After(userLastActivity[userID]) {
+			userLastActivity[userID] = event.CreatedAt
 		}
 	}
 
 	// Convert to slice and sort
 	var activeUsers []models.UserActivity
-	for user, count := range userCounts {
+	for userID, count := range userCounts {
 		// Convert company names map to slice
 		var companyNames []string
-		for companyName := range userCompanyNames[user] {
+		for companyName := range userCompanyNames[userID] {
 			companyNames = append(companyNames, companyName)
 		}
 		sort.Strings(companyNames)
 
 		activeUsers = append(activeUsers, models.UserActivity{
-			User:         user,
+			UserID:       userID,
+			User:         userEmails[userID],
 			EventCount:   count,
-			Companies:    len(userCompanies[user]),
+			Companies:    len(userCompanies[userID]),
 			CompanyNames: companyNames,
-			LastActivity: userLastActivity[user],
+			LastActivity: userLastActivity[userID],
 		})
 	}
 
@@ -1173,17 +1238,33 @@ func (ds *DataService) GetMostActiveUsers(startDate, endDate string, companies [
 		activeUsers = activeUsers[:limit]
 	}
 
+	if q.valueStats {
+		samples := valueSamplesBy(filtered, func(event models.UsageEvent) string { return ds.userID(event.User) })
+		for i := range activeUsers {
+			activeUsers[i].ValueStats = valueStatsFor(samples, activeUsers[i].UserID)
+		}
+	}
+
 	return activeUsers
 }
 
 // GetTopEndpointsByUsage returns top endpoints by usage with filtering support
-func (ds *DataService) GetTopEndpointsByUsage(startDate, endDate string, companies []string, limit int) []models.EndpointActivity {
+func (ds *DataService) GetTopEndpointsByUsage(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.EndpointActivity {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.topEndpointsByUsage(startDate, endDate, companies, limit, ds.newQueryOptions(opts))
+}
+
+// topEndpointsByUsage is GetTopEndpointsByUsage without locking; callers hold ds.mu
+func (ds *DataService) topEndpointsByUsage(startDate, endDate string, companies []string, limit int, q queryOptions) []models.EndpointActivity {
+
 	if !ds.loaded {
 		return []models.EndpointActivity{}
 	}
 
 	// Filter events
-	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies)
+	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies, q)
 
 	// Count events by endpoint
 	endpointCounts := make(map[string]int)
@@ -1198,8 +1279,8 @@ func (ds *DataService) GetTopEndpointsByUsage(startDate, endDate string, compani
 			if endpointUsers[event.Endpoint] == nil {
 				endpointUsers[event.Endpoint] = make(map[string]bool)
 			}
-			if event.User != "" {
-				endpointUsers[event.Endpoint][event.User] = true
+			if userID := ds.userID(event.User); userID != "" {
+				endpointUsers[event.Endpoint][userID] = true
 			}
 
 			// Track unique companies per endpoint
@@ -1236,17 +1317,29 @@ func (ds *DataService) GetTopEndpointsByUsage(startDate, endDate string, compani
 		topEndpoints = topEndpoints[:limit]
 	}
 
+	if q.valueStats {
+		samples := valueSamplesBy(filtered, func(event models.UsageEvent) string { return event.Endpoint })
+		for i := range topEndpoints {
+			topEndpoints[i].ValueStats = valueStatsFor(samples, topEndpoints[i].Endpoint)
+		}
+	}
+
 	return topEndpoints
 }
 
 // GetTopActiveCompaniesWithFiltering returns top active companies with filtering support
-func (ds *DataService) GetTopActiveCompaniesWithFiltering(startDate, endDate string, companies []string, limit int) []models.CompanyActivity {
+func (ds *DataService) GetTopActiveCompaniesWithFiltering(startDate, endDate string, companies []string, limit int, opts ...QueryOption) []models.CompanyActivity {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
 	if !ds.loaded {
 		return []models.CompanyActivity{}
 	}
 
 	// Filter events
-	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies)
+	filtered := ds.filterEventsByDateAndCompanies(startDate, endDate, companies, q)
 
 	// Count events by company
 	companyCounts := make(map[string]int)
@@ -1266,8 +1359,8 @@ func (ds *DataService) GetTopActiveCompaniesWithFiltering(startDate, endDate str
 		if companyUsers[companyName] == nil {
 			companyUsers[companyName] = make(map[string]bool)
 		}
-		if event.User != "" {
-			companyUsers[companyName][event.User] = true
+		if userID := ds.userID(event.User); userID != "" {
+			companyUsers[companyName][userID] = true
 		}
 
 		// Track unique endpoints per company
@@ -1306,54 +1399,36 @@ func (ds *DataService) GetTopActiveCompaniesWithFiltering(startDate, endDate str
 		topCompanies = topCompanies[:limit]
 	}
 
+	if q.valueStats {
+		samples := valueSamplesBy(filtered, func(event models.UsageEvent) string {
+			return companyNameFor(ds.companies, event.CompanyID)
+		})
+		for i := range topCompanies {
+			topCompanies[i].ValueStats = valueStatsFor(samples, topCompanies[i].CompanyName)
+		}
+	}
+
 	return topCompanies
 }
 
 // Helper function to filter events by date and companies
-func (ds *DataService) filterEventsByDateAndCompanies(startDate, endDate string, companies []string) []models.UsageEvent {
-	filtered := ds.events
-
-	// Filter by date range
-	if startDate != "" && endDate != "" {
-		start, _ := time.Parse("2006-01-02", startDate)
-		end, _ := time.Parse("2006-01-02", endDate)
-		end = end.Add(24 * time.Hour)
-
-		var dateFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if event.CreatedAt.After(start) && event.CreatedAt.Before(end) {
-				dateFiltered = append(dateFiltered, event)
-			}
-		}
-		filtered = dateFiltered
-	}
+func (ds *DataService) filterEventsByDateAndCompanies(startDate, endDate string, companies []string, q queryOptions) []models.UsageEvent {
+	return ds.selectEvents(startDate, endDate, companies, q)
+}
 
-	// Filter by companies
-	if len(companies) > 0 {
-		companySet := make(map[string]bool)
-		for _, company := range companies {
-			companySet[company] = true
-		}
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companySet[companyName] {
-				companyFiltered = append(companyFiltered, event)
-			}
-		}
-		filtered = companyFiltered
-	}
+// GetRetentionAnalytics calculates cohort-based retention analytics
+func (ds *DataService) GetRetentionAnalytics(req models.RetentionRequest, opts ...QueryOption) (*models.RetentionResponse, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
 
-	return filtered
+	return ds.retentionAnalytics(req, ds.newQueryOptions(opts))
 }
 
-// GetRetentionAnalytics calculates cohort-based retention analytics
-func (ds *DataService) GetRetentionAnalytics(req models.RetentionRequest) (*models.RetentionResponse, error) {
+// retentionAnalytics is GetRetentionAnalytics without locking; callers hold ds.mu
+func (ds *DataService) retentionAnalytics(req models.RetentionRequest, q queryOptions) (*models.RetentionResponse, error) {
+
 	// Filter events based on request parameters
-	filtered := ds.filterEventsForRetention(req)
+	filtered := ds.filterEventsForRetention(req, q)
 
 	if len(filtered) == 0 {
 		return &models.RetentionResponse{
@@ -1368,7 +1443,7 @@ func (ds *DataService) GetRetentionAnalytics(req models.RetentionRequest) (*mode
 	userActivity := ds.extractUserActivity(filtered)
 
 	// Group users into cohorts
-	cohorts := ds.createCohorts(userActivity, req.CohortPeriod)
+	cohorts := ds.createCohorts(userActivity, newBucketer(req.CohortPeriod, q))
 
 	// Calculate retention for each cohort
 	cohortsWithRetention := ds.calculateCohortRetention(cohorts, req.MinCohortSize)
@@ -1388,40 +1463,13 @@ func (ds *DataService) GetRetentionAnalytics(req models.RetentionRequest) (*mode
 }
 
 // filterEventsForRetention filters events for retention analysis
-func (ds *DataService) filterEventsForRetention(req models.RetentionRequest) []models.UsageEvent {
-	filtered := ds.events
-
-	// Filter by date range
-	if req.StartDate != "" && req.EndDate != "" {
-		start, _ := time.Parse("2006-01-02", req.StartDate)
-		end, _ := time.Parse("2006-01-02", req.EndDate)
-		end = end.Add(24 * time.Hour)
-
-		var dateFiltered []models.UsageEvent
-		for _, event := range filtered {
-			if event.CreatedAt.After(start) && event.CreatedAt.Before(end) {
-				dateFiltered = append(dateFiltered, event)
-			}
-		}
-		filtered = dateFiltered
-	}
-
-	// Filter by company
+func (ds *DataService) filterEventsForRetention(req models.RetentionRequest, q queryOptions) []models.UsageEvent {
+	var companies []string
 	if req.Company != "" {
-		var companyFiltered []models.UsageEvent
-		for _, event := range filtered {
-			companyName := ds.companies[event.CompanyID]
-			if companyName == "" {
-				companyName = "Unknown Company"
-			}
-			if companyName == req.Company {
-				companyFiltered = append(companyFiltered, event)
-			}
-		}
-		filtered = companyFiltered
+		companies = []string{req.Company}
 	}
 
-	return filtered
+	return ds.selectEvents(req.StartDate, req.EndDate, companies, q)
 }
 
 // extractUserActivity extracts user activity timeline from events
@@ -1429,19 +1477,19 @@ func (ds *DataService) extractUserActivity(events []models.UsageEvent) map[strin
 	userActivity := make(map[string]*UserActivityInfo)
 
 	for _, event := range events {
-		// Extract user email from content
-		userEmail := ds.extractUserEmail(event.Content)
-		if userEmail == "" {
+		// Key by identity, so aliases and users active in several companies
+		// form a single cohort member
+		identity := ds.identities.resolve(event.User)
+		userKey := identity.id
+		if userKey == "" {
 			continue
 		}
 
-		userKey := fmt.Sprintf("%s_%s", event.CompanyID, userEmail)
-
 		if userActivity[userKey] == nil {
 			userActivity[userKey] = &UserActivityInfo{
 				CompanyID:   event.CompanyID,
 				CompanyName: ds.companies[event.CompanyID],
-				UserEmail:   userEmail,
+				UserEmail:   identity.email,
 				Activities:  []time.Time{},
 			}
 		}
@@ -1467,8 +1515,8 @@ type UserActivityInfo struct {
 	Activities  []time.Time
 }
 
-// createCohorts groups users into cohorts based on their first activity
-func (ds *DataService) createCohorts(userActivity map[string]*UserActivityInfo, cohortPeriod string) map[string]*CohortInfo {
+// createCohorts groups users into cohorts by the bucket of their first activity
+func (ds *DataService) createCohorts(userActivity map[string]*UserActivityInfo, b bucketer) map[string]*CohortInfo {
 	cohorts := make(map[string]*CohortInfo)
 
 	for userKey, user := range userActivity {
@@ -1477,7 +1525,7 @@ func (ds *DataService) createCohorts(userActivity map[string]*UserActivityInfo,
 		}
 
 		firstActivity := user.Activities[0]
-		cohortDate := ds.getCohortDate(firstActivity, cohortPeriod)
+		cohortDate := b.key(firstActivity)
 
 		if cohorts[cohortDate] == nil {
 			cohorts[cohortDate] = &CohortInfo{
@@ -1498,27 +1546,6 @@ type CohortInfo struct {
 	Users      map[string]*UserActivityInfo
 }
 
-// getCohortDate returns the cohort date based on the period
-func (ds *DataService) getCohortDate(date time.Time, period string) string {
-	switch period {
-	case "weekly":
-		// Group by week (Monday as start of week)
-		weekday := int(date.Weekday())
-		if weekday == 0 { // Sunday
-			weekday = 7
-		}
-		daysToSubtract := weekday - 1
-		weekStart := date.AddDate(0, 0, -daysToSubtract)
-		return weekStart.Format("2006-01-02")
-	case "monthly":
-		// Group by month
-		return date.Format("2006-01")
-	default:
-		// Daily (default)
-		return date.Format("2006-01-02")
-	}
-}
-
 // calculateCohortRetention calculates retention rates for each cohort
 func (ds *DataService) calculateCohortRetention(cohorts map[string]*CohortInfo, minCohortSize int) []models.Cohort {
 	var result []models.Cohort
@@ -1620,24 +1647,3 @@ func (ds *DataService) calculateAverageRetention(cohorts []models.Cohort) float6
 
 	return totalRetention / float64(totalCohorts)
 }
-
-// extractUserEmail extracts user email from content field
-func (ds *DataService) extractUserEmail(content string) string {
-	// Content format: "User active CMMS - Sample Company wes.cherveny@sample.com /work-orders"
-	parts := strings.Split(content, " - ")
-	if len(parts) < 2 {
-		return ""
-	}
-
-	companyAndUser := parts[1]
-	words := strings.Fields(companyAndUser)
-
-	// Find email address (contains @)
-	for _, word := range words {
-		if strings.Contains(word, "@") {
-			return word
-		}
-	}
-
-	return ""
-}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"analytics-dashboard/pkg/models"
+)
+
+const (
+	// hllPrecision is the number of hash bits used to pick a register
+	hllPrecision = 12
+	// hllRegisters is the number of registers in a dense sketch
+	hllRegisters = 1 << hllPrecision
+	// hllSparseLimit is the sparse size at which a sketch switches to dense
+	// registers, roughly where the map outgrows the dense array
+	hllSparseLimit = hllRegisters / 8
+)
+
+// hllSketch is a HyperLogLog distinct-value sketch. Small sketches keep
+// their registers in a sparse map so that rollup cells with a handful of
+// users stay cheap; they switch to a dense register array as they grow.
+type hllSketch struct {
+	sparse map[uint16]uint8
+	dense  []uint8
+}
+
+// newHLLSketch creates an empty sketch
+func newHLLSketch() *hllSketch {
+	return &hllSketch{sparse: make(map[uint16]uint8)}
+}
+
+// add records a value in the sketch
+func (s *hllSketch) add(value string) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	s.setRegister(hllPosition(mix64(h.Sum64())))
+}
+
+// hllPosition splits a hash into the register it updates, taken from the
+// high bits, and rho, one more than the leading zeros of the remaining bits
+func hllPosition(hash uint64) (uint16, uint8) {
+	register := uint16(hash >> (64 - hllPrecision))
+	// Guard bit keeps rho bounded when the remaining bits are all zero
+	rho := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	return register, rho
+}
+
+// setRegister raises a register to rho if it is currently lower
+func (s *hllSketch) setRegister(register uint16, rho uint8) {
+	if s.dense != nil {
+		if rho > s.dense[register] {
+			s.dense[register] = rho
+		}
+		return
+	}
+
+	if rho > s.sparse[register] {
+		s.sparse[register] = rho
+		if len(s.sparse) > hllSparseLimit {
+			s.toDense()
+		}
+	}
+}
+
+// toDense converts a sparse sketch to dense registers
+func (s *hllSketch) toDense() {
+	s.dense = make([]uint8, hllRegisters)
+	for register, rho := range s.sparse {
+		s.dense[register] = rho
+	}
+	s.sparse = nil
+}
+
+// merge folds another sketch into this one
+func (s *hllSketch) merge(other *hllSketch) {
+	if other == nil {
+		return
+	}
+	if other.dense != nil {
+		for register, rho := range other.dense {
+			if rho > 0 {
+				s.setRegister(uint16(register), rho)
+			}
+		}
+		return
+	}
+	for register, rho := range other.sparse {
+		s.setRegister(register, rho)
+	}
+}
+
+// estimate returns the estimated number of distinct values added
+func (s *hllSketch) estimate() float64 {
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+
+	for register := 0; register < hllRegisters; register++ {
+		var rho uint8
+		if s.dense != nil {
+			rho = s.dense[register]
+		} else {
+			rho = s.sparse[uint16(register)]
+		}
+		if rho == 0 {
+			zeros++
+		}
+		sum += 1 / float64(uint64(1)<<rho)
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Small-range correction: linear counting is more accurate while many
+	// registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return estimate
+}
+
+// approxCount converts the sketch estimate into a response with a ~95%
+// (two standard error) bound
+func (s *hllSketch) approxCount() models.ApproxCount {
+	relativeError := 1.04 / math.Sqrt(hllRegisters)
+	estimate := s.estimate()
+
+	return models.ApproxCount{
+		Estimate:      int(math.Round(estimate)),
+		ErrorBound:    int(math.Ceil(2 * relativeError * estimate)),
+		RelativeError: relativeError,
+	}
+}
+
+// mix64 is the MurmurHash3 finalizer, used to spread FNV output across all
+// 64 bits since the register index comes from the high bits
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// countableUser reports whether a user should be counted as a distinct user
+func countableUser(user string) bool {
+	return user != "" && user != "Unknown User"
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHLLPosition(t *testing.T) {
+	cases := []struct {
+		name     string
+		hash     uint64
+		register uint16
+		rho      uint8
+	}{
+		{"first remaining bit set", 0xABC<<52 | 1<<51, 0xABC, 1},
+		{"three leading zeros", 0x001<<52 | 1<<48, 0x001, 4},
+		{"last bit set", 0xFFF<<52 | 1, 0xFFF, 52},
+		{"remaining bits zero", 0x123 << 52, 0x123, 64 - hllPrecision + 1},
+	}
+	for _, tc := range cases {
+		register, rho := hllPosition(tc.hash)
+		if register != tc.register || rho != tc.rho {
+			t.Errorf("%s: hllPosition(%#x) = %#x, %d, want %#x, %d", tc.name, tc.hash, register, rho, tc.register, tc.rho)
+		}
+	}
+}
+
+func TestHLLSketchSwitchesToDense(t *testing.T) {
+	s := newHLLSketch()
+	for register := 0; register < hllSparseLimit; register++ {
+		s.setRegister(uint16(register), 3)
+	}
+	// Lowering or repeating a register does not grow the sparse map
+	s.setRegister(0, 1)
+	if s.dense != nil || len(s.sparse) != hllSparseLimit {
+		t.Fatalf("sketch went dense at %d sparse registers, limit %d", len(s.sparse), hllSparseLimit)
+	}
+	if s.sparse[0] != 3 {
+		t.Errorf("register 0 = %d after a lower rho, want 3", s.sparse[0])
+	}
+
+	s.setRegister(hllRegisters-1, 5)
+	if s.dense == nil || s.sparse != nil {
+		t.Fatal("sketch stayed sparse past the limit")
+	}
+	if len(s.dense) != hllRegisters {
+		t.Fatalf("dense sketch has %d registers, want %d", len(s.dense), hllRegisters)
+	}
+	for register := 0; register < hllSparseLimit; register++ {
+		if s.dense[register] != 3 {
+			t.Fatalf("register %d = %d after the switch, want 3", register, s.dense[register])
+		}
+	}
+	if s.dense[hllRegisters-1] != 5 {
+		t.Errorf("last register = %d, want 5", s.dense[hllRegisters-1])
+	}
+}
+
+func TestHLLSketchMergeKeepsMaxRegisters(t *testing.T) {
+	a, b := newHLLSketch(), newHLLSketch()
+	a.setRegister(1, 2)
+	a.setRegister(2, 7)
+	b.setRegister(2, 4)
+	b.setRegister(3, 1)
+
+	a.merge(b)
+	a.merge(nil)
+	for register, want := range map[uint16]uint8{1: 2, 2: 7, 3: 1} {
+		if got := a.sparse[register]; got != want {
+			t.Errorf("register %d = %d after merge, want %d", register, got, want)
+		}
+	}
+
+	// A dense sketch merges register by register, so a sparse sketch only
+	// switches once it passes the sparse limit
+	dense := newHLLSketch()
+	dense.toDense()
+	dense.setRegister(4, 9)
+	a.merge(dense)
+	if a.dense != nil {
+		t.Error("merge of a nearly empty dense sketch made the sketch dense")
+	}
+	for register, want := range map[uint16]uint8{1: 2, 2: 7, 3: 1, 4: 9} {
+		if got := a.sparse[register]; got != want {
+			t.Errorf("register %d = %d after dense merge, want %d", register, got, want)
+		}
+	}
+
+	for register := 0; register < hllRegisters; register++ {
+		dense.setRegister(uint16(register), 1)
+	}
+	a.merge(dense)
+	if a.dense == nil {
+		t.Fatal("merge of a full dense sketch left the sketch sparse")
+	}
+	if a.dense[2] != 7 || a.dense[4] != 9 || a.dense[hllRegisters-1] != 1 {
+		t.Errorf("registers 2, 4 and last = %d, %d, %d after full merge, want 7, 9, 1", a.dense[2], a.dense[4], a.dense[hllRegisters-1])
+	}
+}
+
+func TestHLLEstimateUsesLinearCountingWhenSmall(t *testing.T) {
+	if estimate := newHLLSketch().estimate(); estimate != 0 {
+		t.Errorf("empty sketch estimate = %f, want 0", estimate)
+	}
+
+	s := newHLLSketch()
+	const filled = 100
+	for register := 0; register < filled; register++ {
+		s.setRegister(uint16(register*7), 1)
+	}
+	m := float64(hllRegisters)
+	want := m * math.Log(m/(m-filled))
+	if estimate := s.estimate(); math.Abs(estimate-want) > 1e-9 {
+		t.Errorf("estimate with %d filled registers = %f, want linear counting %f", filled, estimate, want)
+	}
+}
+
+func TestHLLEstimateWithinErrorBound(t *testing.T) {
+	for _, n := range []int{10, 100, 1000, 10000, 100000} {
+		s := newHLLSketch()
+		for i := 0; i < n; i++ {
+			s.add(fmt.Sprintf("user-%d@example.com", i))
+			s.add(fmt.Sprintf("user-%d@example.com", i)) // Repeats do not count
+		}
+
+		count := s.approxCount()
+		if diff := math.Abs(float64(count.Estimate - n)); diff > float64(count.ErrorBound) {
+			t.Errorf("n=%d: estimate %d is off by %.0f, more than the bound %d", n, count.Estimate, diff, count.ErrorBound)
+		}
+	}
+}
+
+func TestHLLMergeMatchesSketchOfUnion(t *testing.T) {
+	a, b, union := newHLLSketch(), newHLLSketch(), newHLLSketch()
+	for i := 0; i < 6000; i++ {
+		value := fmt.Sprintf("user-%d", i)
+		a.add(value)
+		union.add(value)
+	}
+	for i := 4000; i < 12000; i++ {
+		value := fmt.Sprintf("user-%d", i)
+		b.add(value)
+		union.add(value)
+	}
+
+	a.merge(b)
+	if a.dense == nil || union.dense == nil {
+		t.Fatal("sketches of 12000 values are still sparse")
+	}
+	if a.estimate() != union.estimate() {
+		t.Errorf("merged estimate %f, want the union's %f", a.estimate(), union.estimate())
+	}
+	for register := range union.dense {
+		if a.dense[register] != union.dense[register] {
+			t.Fatalf("register %d = %d after merge, want %d", register, a.dense[register], union.dense[register])
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Identity merge rules that can be enabled on top of case folding
+const (
+	// MergeRuleStripPlus drops "+tag" suffixes from the local part, so
+	// "jane+test@example.com" resolves to "jane@example.com"
+	MergeRuleStripPlus = "strip_plus"
+	// MergeRuleGmailDots drops dots from Gmail local parts, which Gmail
+	// ignores when delivering mail
+	MergeRuleGmailDots = "gmail_dots"
+)
+
+// identityResolver maps raw user strings to a canonical email and a stable
+// user ID. Emails are case folded, then merge rules apply, then aliases.
+type identityResolver struct {
+	aliases   map[string]string // normalized alias -> normalized canonical email
+	stripPlus bool
+	gmailDots bool
+
+	// resolved caches raw user -> identity for users seen at load or
+	// ingestion. It is only written under the DataService write lock.
+	resolved map[string]userIdentity
+}
+
+// userIdentity is the resolved identity of a raw user string
+type userIdentity struct {
+	id    string
+	email string
+}
+
+// newIdentityResolver creates a resolver from an alias map and merge rule
+// names
+func newIdentityResolver(aliases map[string]string, rules []string) (*identityResolver, error) {
+	r := &identityResolver{
+		aliases:  make(map[string]string),
+		resolved: make(map[string]userIdentity),
+	}
+
+	for _, rule := range rules {
+		switch strings.TrimSpace(rule) {
+		case MergeRuleStripPlus:
+			r.stripPlus = true
+		case MergeRuleGmailDots:
+			r.gmailDots = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown identity merge rule %q", rule)
+		}
+	}
+
+	for alias, canonical := range aliases {
+		r.aliases[r.normalize(alias)] = r.normalize(canonical)
+	}
+
+	// Follow alias chains so every alias points at its final email
+	for alias := range r.aliases {
+		target := r.aliases[alias]
+		for hops := 0; hops < len(r.aliases); hops++ {
+			next, chained := r.aliases[target]
+			if !chained || next == target {
+				break
+			}
+			target = next
+		}
+		r.aliases[alias] = target
+	}
+
+	return r, nil
+}
+
+// normalize case folds an email and applies the merge rules
+func (r *identityResolver) normalize(user string) string {
+	email := strings.ToLower(strings.TrimSpace(user))
+
+	local, domain, found := strings.Cut(email, "@")
+	if !found {
+		return email
+	}
+	if r.stripPlus {
+		local, _, _ = strings.Cut(local, "+")
+	}
+	if r.gmailDots && (domain == "gmail.com" || domain == "googlemail.com") {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// resolve returns the identity of a raw user string. Unknown users resolve
+// to an empty identity.
+func (r *identityResolver) resolve(user string) userIdentity {
+	if identity, cached := r.resolved[user]; cached {
+		return identity
+	}
+	if !countableUser(user) {
+		return userIdentity{}
+	}
+
+	email := r.normalize(user)
+	if canonical, aliased := r.aliases[email]; aliased {
+		email = canonical
+	}
+	sum := sha256.Sum256([]byte(email))
+	return userIdentity{
+		id:    "usr_" + hex.EncodeToString(sum[:6]),
+		email: email,
+	}
+}
+
+// remember resolves and caches the identity of a raw user string; callers
+// must hold the DataService write lock
+func (r *identityResolver) remember(user string) userIdentity {
+	identity, cached := r.resolved[user]
+	if !cached {
+		identity = r.resolve(user)
+		r.resolved[user] = identity
+	}
+	return identity
+}
+
+// ConfigureIdentities sets the alias file and merge rules used to resolve
+// users to identities. The alias file is a JSON object mapping alias emails
+// to canonical emails; an empty path means no aliases. Indexes and rollups
+// are rebuilt if data is already loaded.
+func (ds *DataService) ConfigureIdentities(aliasPath string, rules []string) error {
+	aliases := make(map[string]string)
+	if aliasPath != "" {
+		data, err := os.ReadFile(aliasPath)
+		if err != nil {
+			return fmt.Errorf("failed to read identity aliases: %w", err)
+		}
+		if err := json.Unmarshal(data, &aliases); err != nil {
+			return fmt.Errorf("failed to parse identity aliases %s: %w", aliasPath, err)
+		}
+	}
+
+	identities, err := newIdentityResolver(aliases, rules)
+	if err != nil {
+		return err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.identities = identities
+	if ds.loaded {
+		ds.index = buildEventIndex(ds.events, ds.companies, ds.identities)
+		ds.rollups = buildRollups(ds.events, ds.identities)
+		ds.generation++
+	}
+	return nil
+}
+
+// UserID returns the stable ID of a user email or alias, or "" for unknown
+// users
+func (ds *DataService) UserID(user string) string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.userID(user)
+}
+
+// userID returns the stable ID of a raw user string, or "" for unknown users
+func (ds *DataService) userID(user string) string {
+	return ds.identities.resolve(user).id
+}
+
+// userEmail returns the canonical email of a raw user string
+func (ds *DataService) userEmail(user string) string {
+	return ds.identities.resolve(user).email
+}
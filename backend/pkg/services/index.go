@@ -0,0 +1,210 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// eventIndex holds secondary indexes over the time-sorted event slice.
+// Positions stored in the posting lists refer to indexes into ds.events and
+// are kept in ascending order, so they are also ordered by CreatedAt.
+type eventIndex struct {
+	days      []dayPartition
+	companies map[string][]int // company name -> event positions
+	users     map[string][]int // user ID -> event positions
+}
+
+// dayPartition describes the contiguous run of events created on one UTC day
+type dayPartition struct {
+	day   time.Time
+	start int
+	end   int
+}
+
+// buildEventIndex builds day partitions and posting lists for sorted events.
+// User posting lists are keyed by resolved user ID.
+func buildEventIndex(events []models.UsageEvent, companies map[string]string, identities *identityResolver) *eventIndex {
+	idx := &eventIndex{
+		companies: make(map[string][]int),
+		users:     make(map[string][]int),
+	}
+
+	for i := range events {
+		idx.add(i, events[i], companyNameFor(companies, events[i].CompanyID), identities.remember(events[i].User).id)
+	}
+
+	return idx
+}
+
+// add appends the event at position pos to the index. Events must be added
+// in CreatedAt order.
+func (idx *eventIndex) add(pos int, event models.UsageEvent, companyName, userID string) {
+	day := event.CreatedAt.UTC().Truncate(24 * time.Hour)
+	if n := len(idx.days); n > 0 && idx.days[n-1].day.Equal(day) {
+		idx.days[n-1].end = pos + 1
+	} else {
+		idx.days = append(idx.days, dayPartition{day: day, start: pos, end: pos + 1})
+	}
+
+	idx.companies[companyName] = append(idx.companies[companyName], pos)
+
+	if userID != "" {
+		idx.users[userID] = append(idx.users[userID], pos)
+	}
+}
+
+// lowerBound returns the position of the first event created at or after t.
+// The day partitions narrow the search to a single day before binary
+// searching within it.
+func (idx *eventIndex) lowerBound(events []models.UsageEvent, t time.Time) int {
+	day := t.UTC().Truncate(24 * time.Hour)
+	p := sort.Search(len(idx.days), func(i int) bool {
+		return !idx.days[i].day.Before(day)
+	})
+	if p == len(idx.days) {
+		return len(events)
+	}
+
+	part := idx.days[p]
+	if part.day.After(day) {
+		return part.start
+	}
+
+	return part.start + sort.Search(part.end-part.start, func(i int) bool {
+		return !events[part.start+i].CreatedAt.Before(t)
+	})
+}
+
+// positionsInRange returns the sub-slice of a sorted posting list that falls
+// within the half-open position range [lo, hi)
+func positionsInRange(postings []int, lo, hi int) []int {
+	from := sort.SearchInts(postings, lo)
+	to := sort.SearchInts(postings, hi)
+	return postings[from:to]
+}
+
+// intersectPostings returns the positions present in both sorted posting lists
+func intersectPostings(a, b []int) []int {
+	var result []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// unionPostings merges sorted posting lists into a single sorted list
+func unionPostings(lists [][]int) []int {
+	switch len(lists) {
+	case 0:
+		return nil
+	case 1:
+		return lists[0]
+	}
+
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+
+	merged := make([]int, 0, total)
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sort.Ints(merged)
+
+	return merged
+}
+
+// companyNameFor resolves a company ID to its display name
+func companyNameFor(companies map[string]string, companyID string) string {
+	if name := companies[companyID]; name != "" {
+		return name
+	}
+	return "Unknown Company"
+}
+
+// parseDateRange parses YYYY-MM-DD start and end dates as midnight in loc
+// into a half-open time range that includes the entire end date. ok is
+// false when either date is missing or malformed.
+func parseDateRange(startDate, endDate string, loc *time.Location) (start, end time.Time, ok bool) {
+	if startDate == "" || endDate == "" {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start, startErr := time.ParseInLocation("2006-01-02", startDate, loc)
+	end, endErr := time.ParseInLocation("2006-01-02", endDate, loc)
+	if startErr != nil || endErr != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end = end.AddDate(0, 0, 1) // Include the entire end date
+
+	return start, end, true
+}
+
+// dateBounds returns the half-open position range of events created within
+// [start, end)
+func (ds *DataService) dateBounds(start, end time.Time) (int, int) {
+	lo := ds.index.lowerBound(ds.events, start)
+	hi := ds.index.lowerBound(ds.events, end)
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// selectEvents returns events within the optional date range, interpreted
+// in the query's location, belonging to any of the given company names, in
+// CreatedAt order. The date range is resolved with binary searches over the
+// day partitions and the company filter by intersecting the company posting
+// lists with that range. The result is limited to the query's tenant and
+// segment.
+func (ds *DataService) selectEvents(startDate, endDate string, companies []string, q queryOptions) []models.UsageEvent {
+	lo, hi := 0, len(ds.events)
+	if start, end, ok := parseDateRange(startDate, endDate, q.location); ok {
+		lo, hi = ds.dateBounds(start, end)
+	}
+
+	// A tenant only ever reads its own company's posting list
+	if len(companies) == 0 && q.tenant != "" {
+		companies = []string{companyNameFor(ds.companies, q.tenant)}
+	}
+
+	if len(companies) == 0 {
+		return ds.scopeEvents(ds.events[lo:hi:hi], q)
+	}
+
+	seen := make(map[string]bool, len(companies))
+	var lists [][]int
+	for _, company := range companies {
+		if seen[company] {
+			continue
+		}
+		seen[company] = true
+		if postings := positionsInRange(ds.index.companies[company], lo, hi); len(postings) > 0 {
+			lists = append(lists, postings)
+		}
+	}
+
+	return ds.scopeEvents(ds.eventsAt(unionPostings(lists)), q)
+}
+
+// eventsAt materializes the events at the given positions
+func (ds *DataService) eventsAt(positions []int) []models.UsageEvent {
+	events := make([]models.UsageEvent, len(positions))
+	for i, pos := range positions {
+		events[i] = ds.events[pos]
+	}
+	return events
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// newBenchmarkDataService builds an in-memory data service with n events
+// spread evenly over a year across 50 companies and 2000 users
+func newBenchmarkDataService(n int) *DataService {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	step := 365 * 24 * time.Hour / time.Duration(n)
+
+	events := make([]models.UsageEvent, n)
+	companies := make(map[string]string)
+	for i := range events {
+		companyID := fmt.Sprintf("company-%d", i%50)
+		companies[companyID] = fmt.Sprintf("Company%d", i%50)
+		events[i] = models.UsageEvent{
+			ID:        fmt.Sprintf("event-%d", i),
+			CreatedAt: base.Add(time.Duration(i) * step),
+			CompanyID: companyID,
+			Type:      "Action",
+			User:      fmt.Sprintf("user%d@example.com", i%2000),
+			Endpoint:  fmt.Sprintf("/work-orders/%d", i%300),
+		}
+	}
+
+	identities, _ := newIdentityResolver(nil, nil)
+	return &DataService{
+		identities: identities,
+		events:     events,
+		companies:  companies,
+		eventTypes: map[string]int{"Action": n},
+		index:      buildEventIndex(events, companies, identities),
+		loaded:     true,
+	}
+}
+
+// linearFilter is the full-scan filter the indexes replace, kept as a baseline
+func linearFilter(ds *DataService, startDate, endDate string, companies []string) []models.UsageEvent {
+	filtered := ds.events
+	if start, end, ok := parseDateRange(startDate, endDate, time.UTC); ok {
+		var dateFiltered []models.UsageEvent
+		for _, event := range filtered {
+			if !event.CreatedAt.Before(start) && event.CreatedAt.Before(end) {
+				dateFiltered = append(dateFiltered, event)
+			}
+		}
+		filtered = dateFiltered
+	}
+
+	if len(companies) > 0 {
+		companySet := make(map[string]bool)
+		for _, company := range companies {
+			companySet[company] = true
+		}
+		var companyFiltered []models.UsageEvent
+		for _, event := range filtered {
+			if companySet[companyNameFor(ds.companies, event.CompanyID)] {
+				companyFiltered = append(companyFiltered, event)
+			}
+		}
+		filtered = companyFiltered
+	}
+
+	return filtered
+}
+
+var benchmarkSink []models.UsageEvent
+
+func BenchmarkFilterDateRange(b *testing.B) {
+	ds := newBenchmarkDataService(200000)
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchmarkSink = linearFilter(ds, "2025-05-01", "2025-05-07", nil)
+		}
+	})
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchmarkSink = ds.selectEvents("2025-05-01", "2025-05-07", nil, ds.newQueryOptions(nil))
+		}
+	})
+}
+
+func BenchmarkFilterDateRangeAndCompanies(b *testing.B) {
+	ds := newBenchmarkDataService(200000)
+	companies := []string{"Company3", "Company17"}
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchmarkSink = linearFilter(ds, "2025-03-01", "2025-06-30", companies)
+		}
+	})
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchmarkSink = ds.selectEvents("2025-03-01", "2025-06-30", companies, ds.newQueryOptions(nil))
+		}
+	})
+}
+
+func BenchmarkGetUniqueUsersCount(b *testing.B) {
+	ds := newBenchmarkDataService(200000)
+
+	for i := 0; i < b.N; i++ {
+		ds.GetUniqueUsersCount("2025-05-01", "2025-05-31", []string{"Company3"})
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// IngestEvents adds events to the in-memory store, keeping the indexes and
+// rollup tables up to date. Events are validated and normalized first; if
+// any event is invalid nothing is ingested.
+func (ds *DataService) IngestEvents(events []models.UsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	started := time.Now()
+
+	batch := make([]models.UsageEvent, len(events))
+	for i, event := range events {
+		if err := ds.prepareEvent(&event); err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+		batch[i] = event
+	}
+
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].CreatedAt.Before(batch[j].CreatedAt)
+	})
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, event := range batch {
+		// Keep the first known name so existing posting lists stay valid
+		if _, exists := ds.companies[event.CompanyID]; !exists {
+			ds.companies[event.CompanyID] = ds.extractCompanyName(event.Content)
+		}
+		ds.eventTypes[event.Type]++
+		ds.rollups.add(event, ds.identities.remember(event.User).id)
+	}
+
+	inOrder := len(ds.events) == 0 || !batch[0].CreatedAt.Before(ds.events[len(ds.events)-1].CreatedAt)
+	if inOrder {
+		// Appending keeps ds.events sorted, so the index can be extended
+		for _, event := range batch {
+			ds.index.add(len(ds.events), event, companyNameFor(ds.companies, event.CompanyID), ds.userID(event.User))
+			ds.events = append(ds.events, event)
+		}
+	} else {
+		// Late events shift positions, so merge into a new slice and reindex
+		merged := make([]models.UsageEvent, 0, len(ds.events)+len(batch))
+		merged = append(merged, ds.events...)
+		merged = append(merged, batch...)
+		sort.SliceStable(merged, func(i, j int) bool {
+			return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+		})
+		ds.events = merged
+		ds.index = buildEventIndex(merged, ds.companies, ds.identities)
+	}
+	ds.loaded = true
+	ds.generation++
+	ds.updatedAt = time.Now().UTC()
+	ds.ingest.Batches++
+	ds.ingest.Events += uint64(len(batch))
+	ds.ingest.Duration += time.Since(started)
+
+	slog.Info("Ingested events", "events", len(batch), "total", len(ds.events))
+	return nil
+}
+
+// prepareEvent validates an incoming event and fills in the fields that
+// LoadData derives from the CSV content
+func (ds *DataService) prepareEvent(event *models.UsageEvent) error {
+	if event.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if event.CompanyID == "" {
+		return fmt.Errorf("company_id is required")
+	}
+	if event.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if event.CreatedAt.IsZero() {
+		return fmt.Errorf("created_at is required")
+	}
+
+	if event.UpdatedAt.IsZero() {
+		event.UpdatedAt = event.CreatedAt
+	}
+	if event.OriginalTimestamp.IsZero() {
+		event.OriginalTimestamp = event.CreatedAt
+	}
+
+	user, endpoint := ds.extractUserAndEndpoint(event.Content)
+	if event.User == "" {
+		event.User = user
+	}
+	if event.Endpoint == "" {
+		event.Endpoint = endpoint
+	}
+
+	// Company names are resolved from the company map when serving events
+	event.CompanyName = ""
+
+	return nil
+}
+
+// IngestBuffer batches incoming events so the indexes and rollup tables are
+// updated under one write lock per batch instead of once per request
+type IngestBuffer struct {
+	ds        *DataService
+	batchSize int
+	interval  time.Duration
+
+	mu      sync.Mutex
+	pending []models.UsageEvent
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewIngestBuffer creates a buffer that flushes into ds whenever batchSize
+// events are pending or interval has elapsed
+func NewIngestBuffer(ds *DataService, batchSize int, interval time.Duration) *IngestBuffer {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &IngestBuffer{
+		ds:        ds,
+		batchSize: batchSize,
+		interval:  interval,
+	}
+}
+
+// Add validates and queues events, flushing when the batch is full. It
+// returns the number of events still pending afterwards.
+func (b *IngestBuffer) Add(events []models.UsageEvent) (int, error) {
+	prepared := make([]models.UsageEvent, len(events))
+	for i, event := range events {
+		if err := b.ds.prepareEvent(&event); err != nil {
+			return b.Pending(), fmt.Errorf("event %d: %w", i, err)
+		}
+		prepared[i] = event
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, prepared...)
+	full := len(b.pending) >= b.batchSize
+	b.mu.Unlock()
+
+	if full {
+		if _, err := b.Flush(); err != nil {
+			return b.Pending(), err
+		}
+	}
+
+	return b.Pending(), nil
+}
+
+// Pending returns the number of queued events
+func (b *IngestBuffer) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Flush ingests all queued events and returns how many were applied
+func (b *IngestBuffer) Flush() (int, error) {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	if err := b.ds.IngestEvents(batch); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// Start begins flushing the buffer periodically in the background
+func (b *IngestBuffer) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := b.Flush(); err != nil {
+					slog.Warn("Failed to flush ingest buffer", "error", err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background flusher and flushes any remaining events
+func (b *IngestBuffer) Stop() (int, error) {
+	if b.stop != nil {
+		close(b.stop)
+		<-b.done
+		b.stop = nil
+	}
+	return b.Flush()
+}
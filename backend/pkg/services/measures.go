@@ -0,0 +1,270 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// Measures that can be computed over the numeric value column. MeasureCount
+// counts events and is the default for time series.
+const (
+	MeasureCount = "count"
+	MeasureSum   = "sum"
+	MeasureAvg   = "avg"
+	MeasureMin   = "min"
+	MeasureMax   = "max"
+	MeasureP50   = "p50"
+	MeasureP90   = "p90"
+	MeasureP99   = "p99"
+)
+
+// IsValidMeasure reports whether measure is a supported measure
+func IsValidMeasure(measure string) bool {
+	switch measure {
+	case MeasureCount, MeasureSum, MeasureAvg, MeasureMin, MeasureMax, MeasureP50, MeasureP90, MeasureP99:
+		return true
+	}
+	return false
+}
+
+// valueSample collects the non-null values of a group of events and counts
+// the events whose value is null
+type valueSample struct {
+	values    []float64
+	nullCount int
+	sorted    bool
+}
+
+// add records an event's value
+func (s *valueSample) add(event models.UsageEvent) {
+	if event.Value == nil {
+		s.nullCount++
+		return
+	}
+	s.values = append(s.values, *event.Value)
+	s.sorted = false
+}
+
+// merge folds another sample into this one
+func (s *valueSample) merge(other *valueSample) {
+	if other == nil {
+		return
+	}
+	s.values = append(s.values, other.values...)
+	s.nullCount += other.nullCount
+	s.sorted = false
+}
+
+// sum returns the sum of the values
+func (s *valueSample) sum() float64 {
+	total := 0.0
+	for _, value := range s.values {
+		total += value
+	}
+	return total
+}
+
+// percentile returns the p-th percentile (0-100) of the values, linearly
+// interpolating between the closest ranks
+func (s *valueSample) percentile(p float64) float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	if !s.sorted {
+		sort.Float64s(s.values)
+		s.sorted = true
+	}
+
+	rank := p / 100 * float64(len(s.values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return s.values[lower] + (s.values[upper]-s.values[lower])*(rank-float64(lower))
+}
+
+// measure returns the named measure over the values. ok is false when the
+// measure is undefined because the sample has no non-null values; a count
+// is always defined.
+func (s *valueSample) measure(measure string) (value float64, ok bool) {
+	if measure == MeasureCount {
+		return float64(len(s.values) + s.nullCount), true
+	}
+	if len(s.values) == 0 {
+		return 0, false
+	}
+
+	switch measure {
+	case MeasureSum:
+		return s.sum(), true
+	case MeasureAvg:
+		return s.sum() / float64(len(s.values)), true
+	case MeasureMin:
+		return s.percentile(0), true
+	case MeasureMax:
+		return s.percentile(100), true
+	case MeasureP50:
+		return s.percentile(50), true
+	case MeasureP90:
+		return s.percentile(90), true
+	case MeasureP99:
+		return s.percentile(99), true
+	}
+	return 0, false
+}
+
+// stats summarizes the sample. Measures are 0 when every value is null,
+// which Count and NullCount make apparent.
+func (s *valueSample) stats() *models.ValueStats {
+	measure := func(name string) float64 {
+		value, _ := s.measure(name)
+		return value
+	}
+	return &models.ValueStats{
+		Count:     len(s.values),
+		NullCount: s.nullCount,
+		Sum:       measure(MeasureSum),
+		Avg:       measure(MeasureAvg),
+		Min:       measure(MeasureMin),
+		Max:       measure(MeasureMax),
+		P50:       measure(MeasureP50),
+		P90:       measure(MeasureP90),
+		P99:       measure(MeasureP99),
+	}
+}
+
+// pointValue returns a defined time series point value
+func pointValue(value float64) *float64 {
+	return &value
+}
+
+// valueSamplesBy groups the values of events by the key keyFn returns
+func valueSamplesBy(events []models.UsageEvent, keyFn func(models.UsageEvent) string) map[string]*valueSample {
+	samples := make(map[string]*valueSample)
+	for _, event := range events {
+		key := keyFn(event)
+		if samples[key] == nil {
+			samples[key] = &valueSample{}
+		}
+		samples[key].add(event)
+	}
+	return samples
+}
+
+// valueStatsFor summarizes the sample for key, or returns nil when no event
+// had that key so the row omits the block
+func valueStatsFor(samples map[string]*valueSample, key string) *models.ValueStats {
+	if samples[key] == nil {
+		return nil
+	}
+	return samples[key].stats()
+}
+
+// allValues groups every event into a single sample keyed by ""
+func allValues(models.UsageEvent) string {
+	return ""
+}
+
+// GetTimeSeriesMeasure returns a measure of the value column per bucket.
+// With splitBy set it returns one series per dimension value, keeping the
+// top series by event volume and folding the rest into "Other" like
+// GetTimeSeriesBreakdown. Null values are excluded from the measure and
+// counted in each point's NullCount; a bucket without non-null values has
+// no value.
+func (ds *DataService) GetTimeSeriesMeasure(timeframe, startDate, endDate string, companies, eventTypes []string, splitBy, measure string, top int, opts ...QueryOption) models.TimeSeriesResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	if !ds.loaded {
+		return models.TimeSeriesResponse{
+			Data:        []models.TimeSeriesData{},
+			Timeframe:   timeframe,
+			SplitBy:     splitBy,
+			Measure:     measure,
+			TotalPoints: 0,
+		}
+	}
+
+	start, end, _ := parseDateRange(startDate, endDate, q.location)
+	b := newBucketer(timeframe, q)
+	filtered := ds.filterByEventTypes(ds.selectEvents(startDate, endDate, companies, q), eventTypes)
+
+	// Sample values per series and bucket, counting events to rank series
+	counts := newSeriesCounts()
+	samples := make(map[string]map[string]*valueSample)
+	for _, event := range filtered {
+		name := ""
+		if splitBy != "" {
+			name = ds.splitValue(event, splitBy)
+		}
+		key := b.key(event.CreatedAt)
+		counts.add(name, key, 1)
+
+		if samples[name] == nil {
+			samples[name] = make(map[string]*valueSample)
+		}
+		if samples[name][key] == nil {
+			samples[name][key] = &valueSample{}
+		}
+		samples[name][key].add(event)
+	}
+
+	series := []string{""}
+	if splitBy != "" {
+		var folded []string
+		series, folded = counts.topSeries(top)
+		if len(folded) > 0 && samples[OtherSeries] == nil {
+			samples[OtherSeries] = make(map[string]*valueSample)
+		}
+		other := samples[OtherSeries]
+		for _, name := range folded {
+			for key, sample := range samples[name] {
+				if other[key] == nil {
+					other[key] = &valueSample{}
+				}
+				other[key].merge(sample)
+			}
+		}
+	}
+
+	var data []models.TimeSeriesData
+	for _, timestamp := range b.series(start, end) {
+		for _, name := range series {
+			eventType := eventTypeLabel(eventTypes)
+			if splitBy == SplitByType {
+				eventType = name
+			}
+
+			// An empty bucket has a count of 0 and no other measure
+			sample := samples[name][timestamp]
+			if sample == nil {
+				sample = &valueSample{}
+			}
+			point := models.TimeSeriesData{
+				Timestamp: timestamp,
+				Series:    name,
+				NullCount: &sample.nullCount,
+				EventType: eventType,
+			}
+			if value, ok := sample.measure(measure); ok {
+				point.Value = pointValue(value)
+			}
+			data = append(data, point)
+		}
+	}
+
+	response := models.TimeSeriesResponse{
+		Data:        data,
+		Timeframe:   b.timeframe,
+		Timezone:    q.location.String(),
+		SplitBy:     splitBy,
+		Measure:     measure,
+		TotalPoints: len(data),
+	}
+	if splitBy != "" {
+		response.Series = series
+	}
+	return response
+}
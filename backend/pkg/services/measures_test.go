@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestMeasureSeparatesNullValues(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := func(v float64) *float64 { return &v }
+	day1 := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	var events []models.UsageEvent
+	for _, e := range []struct {
+		at    time.Time
+		value *float64
+	}{
+		{day1, nil},
+		{day1, nil},
+		{day2, value(4)},
+		{day2, nil},
+		{day2, value(8)},
+	} {
+		events = append(events, models.UsageEvent{
+			ID:        fmt.Sprintf("event-%d", len(events)),
+			CreatedAt: e.at.Add(time.Duration(len(events)) * time.Minute),
+			CompanyID: "cmp-test",
+			Type:      "Action",
+			Content:   "User active CMMS - Test user@test.com /home",
+			User:      "user@test.com",
+			Value:     e.value,
+		})
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	response := ds.GetTimeSeriesMeasure(TimeframeDaily, "2025-06-01", "2025-06-03", nil, nil, "", MeasureAvg, 0)
+	if len(response.Data) != 3 {
+		t.Fatalf("got %d points, want 3", len(response.Data))
+	}
+
+	allNull, mixed, empty := response.Data[0], response.Data[1], response.Data[2]
+	if allNull.Value != nil {
+		t.Errorf("all-null bucket value = %v, want null", *allNull.Value)
+	}
+	if allNull.NullCount == nil || *allNull.NullCount != 2 {
+		t.Errorf("all-null bucket nullCount = %v, want 2", allNull.NullCount)
+	}
+	if mixed.Value == nil || *mixed.Value != 6 {
+		t.Errorf("mixed bucket avg = %v, want 6 from the non-null values", mixed.Value)
+	}
+	if mixed.NullCount == nil || *mixed.NullCount != 1 {
+		t.Errorf("mixed bucket nullCount = %v, want 1", mixed.NullCount)
+	}
+	if empty.Value != nil || empty.NullCount == nil || *empty.NullCount != 0 {
+		t.Errorf("empty bucket = %v/%v, want a null value and nullCount 0", empty.Value, empty.NullCount)
+	}
+
+	// Counts include null values and are always defined
+	counts := ds.GetTimeSeriesMeasure(TimeframeDaily, "2025-06-01", "2025-06-02", nil, nil, "", MeasureCount, 0)
+	for i, want := range []float64{2, 3} {
+		if point := counts.Data[i]; point.Value == nil || *point.Value != want {
+			t.Errorf("count point %d = %v, want %v", i, point.Value, want)
+		}
+	}
+}
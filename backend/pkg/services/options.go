@@ -0,0 +1,88 @@
+package services
+
+import "time"
+
+// QueryOption customizes how a DataService query is evaluated
+type QueryOption func(*queryOptions)
+
+// queryOptions holds the resolved settings for a single query
+type queryOptions struct {
+	location   *time.Location
+	weekStart  time.Weekday
+	valueStats bool
+	segmentID  string
+	segment    map[string]bool // Member user IDs; nil when no segment is requested
+	tenant     string          // Company ID the query is restricted to; "" for all companies
+	noPII      bool            // Search text is not matched against users or raw content
+}
+
+// newQueryOptions applies opts over the service defaults; callers must hold ds.mu
+func (ds *DataService) newQueryOptions(opts []QueryOption) queryOptions {
+	q := queryOptions{
+		location:  time.UTC,
+		weekStart: ds.weekStart,
+	}
+	for _, opt := range opts {
+		opt(&q)
+	}
+
+	if q.segmentID != "" {
+		q.segment = make(map[string]bool)
+		if segment, exists := ds.segments.segments[q.segmentID]; exists {
+			q.segment = ds.segmentMembers(segment, q.tenant)
+		}
+	}
+	return q
+}
+
+// InLocation evaluates date filters, time buckets and cohorts in loc
+// instead of UTC
+func InLocation(loc *time.Location) QueryOption {
+	return func(q *queryOptions) {
+		if loc != nil {
+			q.location = loc
+		}
+	}
+}
+
+// WithWeekStart starts weekly buckets and cohorts on day instead of the
+// configured default
+func WithWeekStart(day time.Weekday) QueryOption {
+	return func(q *queryOptions) {
+		q.weekStart = day
+	}
+}
+
+// WithValueStats adds a summary of the numeric value column to metrics and
+// to each row of the top-N analytics
+func WithValueStats() QueryOption {
+	return func(q *queryOptions) {
+		q.valueStats = true
+	}
+}
+
+// InSegment limits events to those of the saved segment's members. Rollup
+// tables are not split by user, so segmented queries scan raw events.
+func InSegment(id string) QueryOption {
+	return func(q *queryOptions) {
+		q.segmentID = id
+	}
+}
+
+// ForTenant restricts every event the query reads to those of the company
+// with the given ID. Other companies are invisible to the query, including
+// in company lists and segment membership.
+func ForTenant(companyID string) QueryOption {
+	return func(q *queryOptions) {
+		q.tenant = companyID
+	}
+}
+
+// WithoutPersonalSearch keeps search text from matching event users or raw
+// content, which holds emails, so callers that may not see personal data
+// cannot use match counts to confirm a guessed email
+func WithoutPersonalSearch() QueryOption {
+	return func(q *queryOptions) {
+		q.noPII = true
+	}
+}
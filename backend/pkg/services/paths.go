@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// sessionGap is the idle time after which a user's next event starts a
+// new session. Paths never cross a session boundary.
+const sessionGap = 30 * time.Minute
+
+// pathStep is one entry in a user's navigation stream
+type pathStep struct {
+	endpoint     string // endpoint template
+	last         time.Time
+	sessionStart bool
+}
+
+// GetUserPaths returns the most common sequences of endpoint templates that
+// follow (or, anchored on EndEndpoint, precede) the anchor endpoint within a
+// user's session. Repeated hits on the same endpoint collapse into one step.
+func (ds *DataService) GetUserPaths(req models.PathRequest, opts ...QueryOption) models.PathAnalysisResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	anchor, direction := req.StartEndpoint, "forward"
+	if anchor == "" {
+		anchor, direction = req.EndEndpoint, "backward"
+	}
+	anchor = endpointTemplate(anchor)
+
+	response := models.PathAnalysisResponse{
+		Anchor:    anchor,
+		Direction: direction,
+		Steps:     req.Steps,
+		Sequences: []models.PathSequence{},
+		Nodes:     []models.SankeyNode{},
+		Links:     []models.SankeyLink{},
+	}
+	if !ds.loaded {
+		return response
+	}
+
+	// Count every path through the anchor
+	counts := make(map[string]int)
+	paths := make(map[string][]string)
+	for _, stream := range ds.navigationStreams(ds.selectEvents(req.StartDate, req.EndDate, req.Companies, q)) {
+		for i, step := range stream {
+			if step.endpoint != anchor {
+				continue
+			}
+
+			var path []string
+			if direction == "forward" {
+				path = forwardPath(stream, i, req.Steps)
+			} else {
+				path = backwardPath(stream, i, req.Steps)
+			}
+
+			key := strings.Join(path, "\n")
+			counts[key]++
+			paths[key] = path
+			response.TotalPaths++
+		}
+	}
+
+	for key, count := range counts {
+		response.Sequences = append(response.Sequences, models.PathSequence{
+			Steps:      paths[key],
+			Count:      count,
+			Percentage: float64(count) / float64(response.TotalPaths) * 100,
+		})
+	}
+
+	sort.Slice(response.Sequences, func(i, j int) bool {
+		if response.Sequences[i].Count != response.Sequences[j].Count {
+			return response.Sequences[i].Count > response.Sequences[j].Count
+		}
+		return strings.Join(response.Sequences[i].Steps, "\n") < strings.Join(response.Sequences[j].Steps, "\n")
+	})
+
+	if req.Limit > 0 && len(response.Sequences) > req.Limit {
+		response.Sequences = response.Sequences[:req.Limit]
+	}
+
+	response.Nodes, response.Links = sankeyFromSequences(response.Sequences, direction)
+	return response
+}
+
+// navigationStreams groups events by user identity into time-ordered endpoint
+// template streams, marking session starts and collapsing consecutive hits
+// on the same endpoint within a session
+func (ds *DataService) navigationStreams(events []models.UsageEvent) map[string][]pathStep {
+	streams := make(map[string][]pathStep)
+	for _, event := range events {
+		userID := ds.userID(event.User)
+		if userID == "" || event.Endpoint == "" || event.Endpoint == "Unknown Endpoint" {
+			continue
+		}
+
+		endpoint := endpointTemplate(event.Endpoint)
+		stream := streams[userID]
+		n := len(stream)
+		newSession := n == 0 || event.CreatedAt.Sub(stream[n-1].last) > sessionGap
+
+		if !newSession && stream[n-1].endpoint == endpoint {
+			stream[n-1].last = event.CreatedAt
+			continue
+		}
+
+		streams[userID] = append(stream, pathStep{
+			endpoint:     endpoint,
+			last:         event.CreatedAt,
+			sessionStart: newSession,
+		})
+	}
+	return streams
+}
+
+// forwardPath returns the anchor at stream[i] followed by up to steps
+// endpoints from the same session
+func forwardPath(stream []pathStep, i, steps int) []string {
+	path := []string{stream[i].endpoint}
+	for j := i + 1; j < len(stream) && len(path) <= steps && !stream[j].sessionStart; j++ {
+		path = append(path, stream[j].endpoint)
+	}
+	return path
+}
+
+// backwardPath returns up to steps endpoints from the same session followed
+// by the anchor at stream[i]
+func backwardPath(stream []pathStep, i, steps int) []string {
+	from := i
+	for from > 0 && i-from < steps && !stream[from].sessionStart {
+		from--
+	}
+
+	path := make([]string, 0, i-from+1)
+	for j := from; j <= i; j++ {
+		path = append(path, stream[j].endpoint)
+	}
+	return path
+}
+
+// sankeyFromSequences builds Sankey nodes and links from path sequences.
+// Node steps are relative to the anchor, so backward paths use negative
+// steps and the anchor is always step 0.
+func sankeyFromSequences(sequences []models.PathSequence, direction string) ([]models.SankeyNode, []models.SankeyLink) {
+	nodes := make(map[string]models.SankeyNode)
+	linkValues := make(map[[2]string]int)
+
+	for _, sequence := range sequences {
+		offset := 0
+		if direction == "backward" {
+			offset = len(sequence.Steps) - 1
+		}
+
+		var previous string
+		for i, endpoint := range sequence.Steps {
+			step := i - offset
+			id := fmt.Sprintf("%d:%s", step, endpoint)
+			nodes[id] = models.SankeyNode{ID: id, Endpoint: endpoint, Step: step}
+
+			if i > 0 {
+				linkValues[[2]string{previous, id}] += sequence.Count
+			}
+			previous = id
+		}
+	}
+
+	nodeList := make([]models.SankeyNode, 0, len(nodes))
+	for _, node := range nodes {
+		nodeList = append(nodeList, node)
+	}
+	sort.Slice(nodeList, func(i, j int) bool {
+		if nodeList[i].Step != nodeList[j].Step {
+			return nodeList[i].Step < nodeList[j].Step
+		}
+		return nodeList[i].Endpoint < nodeList[j].Endpoint
+	})
+
+	links := make([]models.SankeyLink, 0, len(linkValues))
+	for pair, value := range linkValues {
+		links = append(links, models.SankeyLink{Source: pair[0], Target: pair[1], Value: value})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Value != links[j].Value {
+			return links[i].Value > links[j].Value
+		}
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Target < links[j].Target
+	})
+
+	return nodeList, links
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestRetentionUsesEventUser(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	// Ingested events carry their user explicitly; the content names nobody
+	events := []models.UsageEvent{
+		{ID: "e1", CreatedAt: base, CompanyID: "cmp-test", Type: "Action", Content: "Exported report", User: "Erin@Test.com"},
+		{ID: "e2", CreatedAt: base.AddDate(0, 0, 3), CompanyID: "cmp-test", Type: "Action", Content: "Exported report", User: "erin@test.com"},
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := ds.GetRetentionAnalytics(models.RetentionRequest{CohortPeriod: "daily", MinCohortSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Cohorts) != 1 {
+		t.Fatalf("%d cohorts, want 1: %+v", len(response.Cohorts), response.Cohorts)
+	}
+	cohort := response.Cohorts[0]
+	if cohort.CohortDate != "2025-03-03" || cohort.TotalUsers != 1 {
+		t.Errorf("cohort %s has %d users, want 2025-03-03 with 1", cohort.CohortDate, cohort.TotalUsers)
+	}
+	if week := cohort.RetentionData[1]; week.Days != 7 || week.ActiveUsers != 1 {
+		t.Errorf("7-day retention = %+v, want 1 active user", week)
+	}
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// rollupDims identifies the dimensions an event is pre-aggregated by
+type rollupDims struct {
+	companyID string
+	eventType string
+	endpoint  string // endpoint template, see endpointTemplate
+}
+
+// rollupCell holds the aggregates for one bucket and dimension combination
+type rollupCell struct {
+	count int
+	first time.Time
+	last  time.Time
+	users *hllSketch
+}
+
+// rollupTable stores fixed-width time buckets keyed by their UTC start
+type rollupTable struct {
+	width   time.Duration
+	starts  []int64 // sorted bucket start times (unix seconds)
+	buckets map[int64]map[rollupDims]*rollupCell
+}
+
+// rollups holds the materialized hourly and daily aggregates
+type rollups struct {
+	hourly *rollupTable
+	daily  *rollupTable
+}
+
+// newRollups creates empty hourly and daily rollup tables
+func newRollups() *rollups {
+	return &rollups{
+		hourly: newRollupTable(time.Hour),
+		daily:  newRollupTable(24 * time.Hour),
+	}
+}
+
+// buildRollups aggregates events into fresh rollup tables
+func buildRollups(events []models.UsageEvent, identities *identityResolver) *rollups {
+	r := newRollups()
+	for _, event := range events {
+		r.add(event, identities.remember(event.User).id)
+	}
+	return r
+}
+
+// add folds a single event, whose user resolves to userID, into every
+// rollup table
+func (r *rollups) add(event models.UsageEvent, userID string) {
+	dims := rollupDimsFor(event)
+	r.hourly.add(event, dims, userID)
+	r.daily.add(event, dims, userID)
+}
+
+// rollupDimsFor returns the rollup dimensions of an event
+func rollupDimsFor(event models.UsageEvent) rollupDims {
+	return rollupDims{
+		companyID: event.CompanyID,
+		eventType: event.Type,
+		endpoint:  endpointTemplate(event.Endpoint),
+	}
+}
+
+// tableFor returns the coarsest table no wider than finest whose buckets
+// align with both ends of the range and with bucket boundaries in loc, or
+// nil when no table does. Daily buckets are UTC days, so they only serve
+// UTC queries; hourly buckets serve any zone whose offsets are whole hours.
+func (r *rollups) tableFor(start, end time.Time, loc *time.Location, finest time.Duration) *rollupTable {
+	if loc == time.UTC && r.daily.width <= finest && r.daily.aligned(start) && r.daily.aligned(end) {
+		return r.daily
+	}
+	if r.hourly.aligned(start) && r.hourly.aligned(end) {
+		return r.hourly
+	}
+	return nil
+}
+
+// newRollupTable creates an empty rollup table with the given bucket width
+func newRollupTable(width time.Duration) *rollupTable {
+	return &rollupTable{
+		width:   width,
+		buckets: make(map[int64]map[rollupDims]*rollupCell),
+	}
+}
+
+// aligned reports whether t falls on a bucket boundary of the table
+func (t *rollupTable) aligned(ts time.Time) bool {
+	return ts.IsZero() || ts.Truncate(t.width).Equal(ts)
+}
+
+// add folds an event into its bucket
+func (t *rollupTable) add(event models.UsageEvent, dims rollupDims, userID string) {
+	key := event.CreatedAt.UTC().Truncate(t.width).Unix()
+
+	cells, exists := t.buckets[key]
+	if !exists {
+		cells = make(map[rollupDims]*rollupCell)
+		t.buckets[key] = cells
+
+		// Keep bucket starts sorted; ingestion is usually in time order
+		pos := sort.Search(len(t.starts), func(i int) bool { return t.starts[i] >= key })
+		t.starts = append(t.starts, 0)
+		copy(t.starts[pos+1:], t.starts[pos:])
+		t.starts[pos] = key
+	}
+
+	cell := cells[dims]
+	if cell == nil {
+		cell = &rollupCell{first: event.CreatedAt, last: event.CreatedAt, users: newHLLSketch()}
+		cells[dims] = cell
+	}
+	cell.count++
+	if userID != "" {
+		cell.users.add(userID)
+	}
+	if event.CreatedAt.Before(cell.first) {
+		cell.first = event.CreatedAt
+	}
+	if event.CreatedAt.After(cell.last) {
+		cell.last = event.CreatedAt
+	}
+}
+
+// scan calls fn for every cell in buckets starting within [start, end).
+// A zero start or end leaves that side of the range open.
+func (t *rollupTable) scan(start, end time.Time, fn func(bucket time.Time, dims rollupDims, cell *rollupCell)) {
+	lo, hi := 0, len(t.starts)
+	if !start.IsZero() {
+		s := start.Unix()
+		lo = sort.Search(len(t.starts), func(i int) bool { return t.starts[i] >= s })
+	}
+	if !end.IsZero() {
+		e := end.Unix()
+		hi = sort.Search(len(t.starts), func(i int) bool { return t.starts[i] >= e })
+	}
+
+	for _, key := range t.starts[lo:hi] {
+		bucket := time.Unix(key, 0).UTC()
+		for dims, cell := range t.buckets[key] {
+			fn(bucket, dims, cell)
+		}
+	}
+}
+
+var endpointIDSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// endpointTemplate collapses identifier path segments so that, for example,
+// "/work-orders/2118956" and "/work-orders/2115525" both become
+// "/work-orders/:id"
+func endpointTemplate(endpoint string) string {
+	if !strings.HasPrefix(endpoint, "/") {
+		return endpoint
+	}
+
+	path := endpoint
+	if idx := strings.IndexAny(path, "?#"); idx != -1 {
+		path = path[:idx]
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if endpointIDSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// rollupFilter matches rollup cells against company name and event type
+// filters and the tenant's company ID
+type rollupFilter struct {
+	companies  map[string]bool
+	eventTypes map[string]bool
+	tenant     string
+}
+
+// newRollupFilter builds a filter from company names, event types and a
+// tenant company ID, which may be empty
+func newRollupFilter(companies, eventTypes []string, tenant string) rollupFilter {
+	filter := rollupFilter{tenant: tenant}
+	if len(companies) > 0 {
+		filter.companies = make(map[string]bool)
+		for _, company := range companies {
+			filter.companies[company] = true
+		}
+	}
+	if len(eventTypes) > 0 {
+		filter.eventTypes = make(map[string]bool)
+		for _, eventType := range eventTypes {
+			filter.eventTypes[eventType] = true
+		}
+	}
+	return filter
+}
+
+// rollupMatches reports whether a cell passes the filter
+func (ds *DataService) rollupMatches(filter rollupFilter, dims rollupDims) bool {
+	if filter.tenant != "" && dims.companyID != filter.tenant {
+		return false
+	}
+	if filter.companies != nil && !filter.companies[companyNameFor(ds.companies, dims.companyID)] {
+		return false
+	}
+	if filter.eventTypes != nil && !filter.eventTypes[dims.eventType] {
+		return false
+	}
+	return true
+}
+
+// timeSeriesFromRollups builds /trends data points from the rollup table
+// aligned with the requested range. ok is false when no table can serve it
+// or a segment needs per-user filtering.
+func (ds *DataService) timeSeriesFromRollups(b bucketer, start, end time.Time, companies, eventTypes []string, q queryOptions) (map[string]int, bool) {
+	table := ds.rollups.tableFor(start, end, b.location, b.finestWidth())
+	if table == nil || q.segment != nil {
+		return nil, false
+	}
+
+	filter := newRollupFilter(companies, eventTypes, q.tenant)
+	timeSeriesMap := make(map[string]int)
+	table.scan(start, end, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if ds.rollupMatches(filter, dims) {
+			timeSeriesMap[b.key(bucket)] += cell.count
+		}
+	})
+
+	return timeSeriesMap, true
+}
+
+// multiCompanySeriesFromRollups builds /trends/multi-company buckets from
+// the rollup table aligned with the requested range
+func (ds *DataService) multiCompanySeriesFromRollups(b bucketer, start, end time.Time, companies, eventTypes []string, q queryOptions) (map[string]map[string]int, bool) {
+	table := ds.rollups.tableFor(start, end, b.location, b.finestWidth())
+	if table == nil || q.segment != nil {
+		return nil, false
+	}
+
+	filter := newRollupFilter(companies, eventTypes, q.tenant)
+	dateCompanyMap := make(map[string]map[string]int)
+	table.scan(start, end, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if !ds.rollupMatches(filter, dims) {
+			return
+		}
+		dateKey := b.key(bucket)
+		if dateCompanyMap[dateKey] == nil {
+			dateCompanyMap[dateKey] = make(map[string]int)
+		}
+		dateCompanyMap[dateKey][companyNameFor(ds.companies, dims.companyID)] += cell.count
+	})
+
+	return dateCompanyMap, true
+}
+
+// metricsFromRollups computes /metrics from the rollup table aligned with
+// the requested range
+func (ds *DataService) metricsFromRollups(start, end time.Time, companies, eventTypes []string, q queryOptions) (models.MetricsResponse, bool) {
+	table := ds.rollups.tableFor(start, end, q.location, 24*time.Hour)
+	if table == nil || q.segment != nil {
+		return models.MetricsResponse{}, false
+	}
+
+	filter := newRollupFilter(companies, eventTypes, q.tenant)
+	totalEvents := 0
+	companySet := make(map[string]bool)
+	eventTypeCounts := make(map[string]int)
+	var first, last time.Time
+
+	table.scan(start, end, func(bucket time.Time, dims rollupDims, cell *rollupCell) {
+		if !ds.rollupMatches(filter, dims) {
+			return
+		}
+		totalEvents += cell.count
+		companySet[dims.companyID] = true
+		eventTypeCounts[dims.eventType] += cell.count
+		if first.IsZero() || cell.first.Before(first) {
+			first = cell.first
+		}
+		if cell.last.After(last) {
+			last = cell.last
+		}
+	})
+
+	var topEventTypes []models.EventTypeCount
+	for eventType, count := range eventTypeCounts {
+		topEventTypes = append(topEventTypes, models.EventTypeCount{
+			Type:  eventType,
+			Count: count,
+		})
+	}
+
+	// Sort by count (descending)
+	sort.Slice(topEventTypes, func(i, j int) bool {
+		return topEventTypes[i].Count > topEventTypes[j].Count
+	})
+
+	var timeRange models.TimeRange
+	if totalEvents > 0 {
+		timeRange.Start = first.Format("2006-01-02T15:04:05Z")
+		timeRange.End = last.Format("2006-01-02T15:04:05Z")
+	}
+
+	return models.MetricsResponse{
+		TotalEvents:     totalEvents,
+		ActiveCompanies: len(companySet),
+		TopEventTypes:   topEventTypes,
+		TimeRange:       timeRange,
+	}, true
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestSearchEventsIncludesRangeStart(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 4, 7, 0, 0, 0, 0, time.UTC)
+	events := []models.UsageEvent{
+		{ID: "before", CreatedAt: start.Add(-time.Second), CompanyID: "cmp-acme", Type: "Action", Content: "User active CMMS - Acme ann@acme.com /home"},
+		{ID: "at-start", CreatedAt: start, CompanyID: "cmp-acme", Type: "Action", Content: "User active CMMS - Acme ann@acme.com /home"},
+		{ID: "other-company", CreatedAt: start.Add(time.Hour), CompanyID: "cmp-globex", Type: "Action", Content: "User active CMMS - Globex gus@globex.com /home"},
+		{ID: "last-day", CreatedAt: start.AddDate(0, 0, 1).Add(23 * time.Hour), CompanyID: "cmp-acme", Type: "Action", Content: "User active CMMS - Acme ann@acme.com /home"},
+		{ID: "after", CreatedAt: start.AddDate(0, 0, 2), CompanyID: "cmp-acme", Type: "Action", Content: "User active CMMS - Acme ann@acme.com /home"},
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	response := ds.SearchEvents(models.SearchRequest{
+		Filters: models.SearchFilters{
+			DateRange: &models.DateRange{Start: "2025-04-07", End: "2025-04-08"},
+			Companies: []string{ds.GetCompanyName("cmp-acme")},
+		},
+		Pagination: models.PaginationRequest{Page: 1, PageSize: 10},
+	})
+
+	found := make(map[string]bool)
+	for _, event := range response.Data {
+		found[event.ID] = true
+	}
+	if len(found) != 2 || !found["at-start"] || !found["last-day"] {
+		t.Errorf("found events %v, want at-start and last-day", found)
+	}
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// Fields a segment rule can match on
+const (
+	SegmentFieldCompany     = "company"
+	SegmentFieldEventType   = "eventType"
+	SegmentFieldEndpoint    = "endpoint"
+	SegmentFieldEmail       = "email"
+	SegmentFieldEmailDomain = "emailDomain"
+	SegmentFieldEventCount  = "eventCount"
+)
+
+// Segment rule operators
+const (
+	SegmentOpEq       = "eq"
+	SegmentOpNeq      = "neq"
+	SegmentOpIn       = "in"
+	SegmentOpContains = "contains"
+	SegmentOpGt       = "gt"
+	SegmentOpGte      = "gte"
+	SegmentOpLt       = "lt"
+	SegmentOpLte      = "lte"
+)
+
+// ErrSegmentNotFound is returned when a segment ID does not exist
+var ErrSegmentNotFound = errors.New("segment not found")
+
+// ErrInvalidSegment is wrapped by segment validation errors
+var ErrInvalidSegment = errors.New("invalid segment")
+
+// segmentStore holds the saved segments and the file they are persisted to.
+// It is guarded by the DataService lock so that segment changes and the
+// generation bump that invalidates cached results happen together.
+type segmentStore struct {
+	path     string
+	segments map[string]models.Segment
+}
+
+// newSegmentStore creates an in-memory segment store without persistence
+func newSegmentStore() *segmentStore {
+	return &segmentStore{
+		segments: make(map[string]models.Segment),
+	}
+}
+
+// ConfigureSegments persists segments to path, loading any that were saved
+// there before
+func (ds *DataService) ConfigureSegments(path string) error {
+	segments := make(map[string]models.Segment)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read segments: %w", err)
+	}
+	if err == nil {
+		var saved []models.Segment
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to parse segments %s: %w", path, err)
+		}
+		for _, segment := range saved {
+			if err := validateSegment(&segment); err != nil {
+				return fmt.Errorf("segment %s in %s: %w", segment.ID, path, err)
+			}
+			segments[segment.ID] = segment
+		}
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.segments.path = path
+	ds.segments.segments = segments
+	ds.generation++
+	return nil
+}
+
+// ListSegments returns every saved segment with its member count, by name
+func (ds *DataService) ListSegments(opts ...QueryOption) models.SegmentsResponse {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	data := make([]models.SegmentResponse, 0, len(ds.segments.segments))
+	for _, segment := range ds.segments.segments {
+		data = append(data, ds.segmentResponse(segment, q.tenant))
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Name != data[j].Name {
+			return data[i].Name < data[j].Name
+		}
+		return data[i].ID < data[j].ID
+	})
+
+	return models.SegmentsResponse{
+		Data:  data,
+		Total: len(data),
+	}
+}
+
+// GetSegment returns a saved segment with its member count
+func (ds *DataService) GetSegment(id string, opts ...QueryOption) (models.SegmentResponse, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	segment, exists := ds.segments.segments[id]
+	if !exists {
+		return models.SegmentResponse{}, false
+	}
+	return ds.segmentResponse(segment, q.tenant), true
+}
+
+// SegmentExists reports whether a segment with the given ID is saved
+func (ds *DataService) SegmentExists(id string) bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	_, exists := ds.segments.segments[id]
+	return exists
+}
+
+// CreateSegment validates and saves a new segment
+func (ds *DataService) CreateSegment(segment models.Segment) (models.SegmentResponse, error) {
+	if err := validateSegment(&segment); err != nil {
+		return models.SegmentResponse{}, err
+	}
+
+	id, err := newSegmentID()
+	if err != nil {
+		return models.SegmentResponse{}, err
+	}
+	segment.ID = id
+	segment.CreatedAt = time.Now().UTC()
+	segment.UpdatedAt = segment.CreatedAt
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.putSegment(segment); err != nil {
+		return models.SegmentResponse{}, err
+	}
+	return ds.segmentResponse(segment, ""), nil
+}
+
+// UpdateSegment replaces the name, description and rules of a saved segment
+func (ds *DataService) UpdateSegment(id string, segment models.Segment) (models.SegmentResponse, error) {
+	if err := validateSegment(&segment); err != nil {
+		return models.SegmentResponse{}, err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	existing, exists := ds.segments.segments[id]
+	if !exists {
+		return models.SegmentResponse{}, ErrSegmentNotFound
+	}
+	segment.ID = id
+	segment.CreatedAt = existing.CreatedAt
+	segment.UpdatedAt = time.Now().UTC()
+
+	if err := ds.putSegment(segment); err != nil {
+		return models.SegmentResponse{}, err
+	}
+	return ds.segmentResponse(segment, ""), nil
+}
+
+// DeleteSegment removes a saved segment
+func (ds *DataService) DeleteSegment(id string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	existing, exists := ds.segments.segments[id]
+	if !exists {
+		return ErrSegmentNotFound
+	}
+
+	delete(ds.segments.segments, id)
+	if err := ds.segments.save(); err != nil {
+		ds.segments.segments[id] = existing
+		return err
+	}
+	ds.generation++
+	return nil
+}
+
+// putSegment stores and persists a segment, restoring the previous version
+// if the file cannot be written; callers must hold the write lock
+func (ds *DataService) putSegment(segment models.Segment) error {
+	previous, existed := ds.segments.segments[segment.ID]
+	ds.segments.segments[segment.ID] = segment
+
+	if err := ds.segments.save(); err != nil {
+		if existed {
+			ds.segments.segments[segment.ID] = previous
+		} else {
+			delete(ds.segments.segments, segment.ID)
+		}
+		return err
+	}
+
+	// Cached results for this segment are stale now
+	ds.generation++
+	return nil
+}
+
+// segmentResponse adds the current member count to a segment
+func (ds *DataService) segmentResponse(segment models.Segment, tenant string) models.SegmentResponse {
+	return models.SegmentResponse{
+		Segment: segment,
+		Users:   len(ds.segmentMembers(segment, tenant)),
+	}
+}
+
+// tenantPositions keeps the event positions belonging to the tenant's company
+func (ds *DataService) tenantPositions(positions []int, tenant string) []int {
+	var kept []int
+	for _, pos := range positions {
+		if ds.events[pos].CompanyID == tenant {
+			kept = append(kept, pos)
+		}
+	}
+	return kept
+}
+
+// save writes the segments to the segments file; callers must hold the
+// DataService write lock
+func (s *segmentStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	saved := make([]models.Segment, 0, len(s.segments))
+	for _, segment := range s.segments {
+		saved = append(saved, segment)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		return saved[i].ID < saved[j].ID
+	})
+
+	return writeJSONFile(s.path, saved, "segments")
+}
+
+// newSegmentID returns a random segment ID
+func newSegmentID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate segment id: %w", err)
+	}
+	return "seg_" + hex.EncodeToString(b), nil
+}
+
+// validateSegment checks a segment's name and rules, normalizing endpoint
+// values to templates so they match like the rest of the analytics
+func validateSegment(segment *models.Segment) error {
+	segment.Name = strings.TrimSpace(segment.Name)
+	if segment.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSegment)
+	}
+	if len(segment.Rules) == 0 {
+		return fmt.Errorf("%w: at least one rule is required", ErrInvalidSegment)
+	}
+
+	for i := range segment.Rules {
+		rule := &segment.Rules[i]
+		rule.Value = strings.TrimSpace(rule.Value)
+		if rule.WindowDays < 0 {
+			return fmt.Errorf("%w: rule %d: windowDays must not be negative", ErrInvalidSegment, i+1)
+		}
+
+		switch rule.Field {
+		case SegmentFieldEventCount:
+			switch rule.Operator {
+			case SegmentOpEq, SegmentOpGt, SegmentOpGte, SegmentOpLt, SegmentOpLte:
+			default:
+				return fmt.Errorf("%w: rule %d: eventCount operator must be one of: eq, gt, gte, lt, lte", ErrInvalidSegment, i+1)
+			}
+			if _, err := strconv.Atoi(rule.Value); err != nil {
+				return fmt.Errorf("%w: rule %d: eventCount value must be an integer", ErrInvalidSegment, i+1)
+			}
+		case SegmentFieldCompany, SegmentFieldEventType, SegmentFieldEndpoint, SegmentFieldEmail, SegmentFieldEmailDomain:
+			switch rule.Operator {
+			case SegmentOpEq, SegmentOpNeq, SegmentOpIn, SegmentOpContains:
+			default:
+				return fmt.Errorf("%w: rule %d: operator must be one of: eq, neq, in, contains", ErrInvalidSegment, i+1)
+			}
+			if rule.Value == "" {
+				return fmt.Errorf("%w: rule %d: value is required", ErrInvalidSegment, i+1)
+			}
+			if rule.Field == SegmentFieldEndpoint && rule.Operator != SegmentOpContains {
+				values := strings.Split(rule.Value, ",")
+				for j, value := range values {
+					values[j] = endpointTemplate(strings.TrimSpace(value))
+				}
+				rule.Value = strings.Join(values, ",")
+			}
+		default:
+			return fmt.Errorf("%w: rule %d: field must be one of: company, eventType, endpoint, email, emailDomain, eventCount", ErrInvalidSegment, i+1)
+		}
+	}
+	return nil
+}
+
+// segmentMembers returns the IDs of the users matching every rule of the
+// segment. With a tenant, rules only see that company's events and users
+// without any are not members. Callers must hold ds.mu.
+func (ds *DataService) segmentMembers(segment models.Segment, tenant string) map[string]bool {
+	members := make(map[string]bool)
+	if len(ds.events) == 0 {
+		return members
+	}
+
+	// Windows are relative to the newest event, so they work the same on
+	// historical datasets and on live ingested data
+	latest := ds.events[len(ds.events)-1].CreatedAt
+
+	for userID, postings := range ds.index.users {
+		if tenant != "" {
+			postings = ds.tenantPositions(postings, tenant)
+			if len(postings) == 0 {
+				continue
+			}
+		}
+
+		email := ds.userEmail(ds.events[postings[0]].User)
+		matched := true
+		for _, rule := range segment.Rules {
+			if !ds.userMatchesRule(email, postings, rule, latest) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			members[userID] = true
+		}
+	}
+	return members
+}
+
+// userMatchesRule reports whether a user, given by canonical email and
+// event positions, matches a segment rule
+func (ds *DataService) userMatchesRule(email string, postings []int, rule models.SegmentRule, latest time.Time) bool {
+	switch rule.Field {
+	case SegmentFieldEmail:
+		return matchSegmentValue(rule.Operator, email, rule.Value)
+	case SegmentFieldEmailDomain:
+		_, domain, _ := strings.Cut(email, "@")
+		return matchSegmentValue(rule.Operator, domain, rule.Value)
+	}
+
+	lo, hi := 0, len(ds.events)
+	if rule.WindowDays > 0 {
+		lo, hi = ds.dateBounds(latest.AddDate(0, 0, -rule.WindowDays), latest.Add(time.Nanosecond))
+	}
+	positions := positionsInRange(postings, lo, hi)
+
+	if rule.Field == SegmentFieldEventCount {
+		threshold, _ := strconv.Atoi(rule.Value)
+		return compareCount(rule.Operator, len(positions), threshold)
+	}
+
+	// Event fields match when any event has the value; neq matches when
+	// no event does
+	operator := rule.Operator
+	if operator == SegmentOpNeq {
+		operator = SegmentOpEq
+	}
+	found := false
+	for _, pos := range positions {
+		if matchSegmentValue(operator, ds.segmentFieldValue(ds.events[pos], rule.Field), rule.Value) {
+			found = true
+			break
+		}
+	}
+	return found != (rule.Operator == SegmentOpNeq)
+}
+
+// segmentFieldValue returns the value of an event field a rule matches on
+func (ds *DataService) segmentFieldValue(event models.UsageEvent, field string) string {
+	switch field {
+	case SegmentFieldCompany:
+		return companyNameFor(ds.companies, event.CompanyID)
+	case SegmentFieldEventType:
+		return event.Type
+	case SegmentFieldEndpoint:
+		return endpointTemplate(event.Endpoint)
+	}
+	return ""
+}
+
+// matchSegmentValue compares a value against a rule value, ignoring case
+func matchSegmentValue(operator, actual, value string) bool {
+	switch operator {
+	case SegmentOpEq:
+		return strings.EqualFold(actual, value)
+	case SegmentOpNeq:
+		return !strings.EqualFold(actual, value)
+	case SegmentOpIn:
+		for _, candidate := range strings.Split(value, ",") {
+			if strings.EqualFold(actual, strings.TrimSpace(candidate)) {
+				return true
+			}
+		}
+		return false
+	case SegmentOpContains:
+		return strings.Contains(strings.ToLower(actual), strings.ToLower(value))
+	}
+	return false
+}
+
+// compareCount compares an event count against a rule threshold
+func compareCount(operator string, count, threshold int) bool {
+	switch operator {
+	case SegmentOpEq:
+		return count == threshold
+	case SegmentOpGt:
+		return count > threshold
+	case SegmentOpGte:
+		return count >= threshold
+	case SegmentOpLt:
+		return count < threshold
+	case SegmentOpLte:
+		return count <= threshold
+	}
+	return false
+}
+
+// scopeEvents keeps the events a query may see: those of its tenant's
+// company and, when a segment is requested, of the segment's members
+func (ds *DataService) scopeEvents(events []models.UsageEvent, q queryOptions) []models.UsageEvent {
+	if q.tenant == "" && q.segment == nil {
+		return events
+	}
+
+	var scoped []models.UsageEvent
+	for _, event := range events {
+		if q.tenant != "" && event.CompanyID != q.tenant {
+			continue
+		}
+		if q.segment != nil && !q.segment[ds.userID(event.User)] {
+			continue
+		}
+		scoped = append(scoped, event)
+	}
+	return scoped
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+const (
+	acmeID   = "cmp-acme"
+	globexID = "cmp-globex"
+)
+
+// newTenantDataService builds a data service with two companies over
+// January 2025. carol@shared.com uses both, so per-user views must be cut
+// down to the tenant's events rather than hidden outright.
+func newTenantDataService(t *testing.T) *DataService {
+	t.Helper()
+
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	var events []models.UsageEvent
+	add := func(companyID, company, eventType, user, endpoint string, at time.Time) {
+		events = append(events, models.UsageEvent{
+			ID:        fmt.Sprintf("event-%d", len(events)),
+			CreatedAt: at,
+			CompanyID: companyID,
+			Type:      eventType,
+			Content:   fmt.Sprintf("User active CMMS - %s %s %s", company, user, endpoint),
+			User:      user,
+			Endpoint:  endpoint,
+		})
+	}
+	for day := 0; day < 20; day++ {
+		at := base.AddDate(0, 0, day)
+		add(acmeID, "Acme", "Action", "alice@acme.com", "/work-orders", at)
+		add(acmeID, "Acme", "Action", "carol@shared.com", "/work-orders/1", at.Add(time.Hour))
+		add(globexID, "Globex", "GlobexOnly", "bob@globex.com", "/globex-assets", at.Add(2*time.Hour))
+		add(globexID, "Globex", "GlobexOnly", "carol@shared.com", "/globex-assets/1", at.Add(3*time.Hour))
+	}
+
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+// assertNoGlobex fails if v, encoded as JSON, mentions anything that only
+// exists in Globex's events
+func assertNoGlobex(t *testing.T, ds *DataService, name string, v interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	body := string(data)
+	for _, marker := range []string{"Globex", globexID, "bob@globex.com", ds.userID("bob@globex.com"), "globex-assets", "GlobexOnly"} {
+		if strings.Contains(body, marker) {
+			t.Errorf("%s leaks %q: %s", name, marker, body)
+		}
+	}
+}
+
+func TestTenantQueriesExcludeOtherCompanies(t *testing.T) {
+	ds := newTenantDataService(t)
+	tenant := ForTenant(acmeID)
+	start, end := "2025-01-01", "2025-01-31"
+
+	search := ds.SearchEvents(models.SearchRequest{Pagination: models.PaginationRequest{Page: 1, PageSize: 100}}, tenant)
+	if search.Pagination.Total != 40 {
+		t.Errorf("SearchEvents total = %d, want 40", search.Pagination.Total)
+	}
+	events, total := ds.GetAllEvents(1, 100, tenant)
+	if total != 40 {
+		t.Errorf("GetAllEvents total = %d, want 40", total)
+	}
+
+	retention, err := ds.GetRetentionAnalytics(models.RetentionRequest{StartDate: start, EndDate: end, CohortPeriod: "weekly"}, tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := map[string]interface{}{
+		"SearchEvents":                       search,
+		"GetAllEvents":                       events,
+		"GetTimeSeriesData":                  ds.GetTimeSeriesData(TimeframeDaily, start, end, nil, nil, tenant),
+		"GetTimeSeriesData hourly":           ds.GetTimeSeriesData(TimeframeHourly, "2025-01-02", "2025-01-02", nil, nil, tenant),
+		"GetTimeSeriesBreakdown company":     ds.GetTimeSeriesBreakdown(TimeframeDaily, start, end, nil, nil, SplitByCompany, 0, tenant),
+		"GetTimeSeriesBreakdown user":        ds.GetTimeSeriesBreakdown(TimeframeDaily, start, end, nil, nil, SplitByUser, 0, tenant),
+		"GetTimeSeriesBreakdown endpoint":    ds.GetTimeSeriesBreakdown(TimeframeWeekly, start, end, nil, nil, SplitByEndpoint, 0, tenant),
+		"GetTimeSeriesMeasure":               ds.GetTimeSeriesMeasure(TimeframeDaily, start, end, nil, nil, SplitByType, MeasureCount, 0, tenant),
+		"GetMultiCompanyTimeSeriesData":      ds.GetMultiCompanyTimeSeriesData(TimeframeDaily, start, end, nil, nil, tenant),
+		"GetMetrics":                         ds.GetMetrics(start, end, nil, nil, tenant),
+		"GetMetrics partial day":             ds.GetMetrics("2025-01-03", "2025-01-05", nil, nil, tenant, WithValueStats()),
+		"GetAllCompanyNames":                 ds.GetAllCompanyNames(tenant),
+		"GetCompanies":                       ds.GetCompanies(tenant),
+		"GetTopActiveCompanies":              ds.GetTopActiveCompanies(tenant),
+		"GetEventDistribution":               ds.GetEventDistribution(tenant),
+		"GetTopEventsByVolume":               ds.GetTopEventsByVolume(start, end, nil, 10, tenant),
+		"GetMostActiveUsers":                 ds.GetMostActiveUsers(start, end, nil, 10, tenant),
+		"GetTopEndpointsByUsage":             ds.GetTopEndpointsByUsage(start, end, nil, 10, tenant),
+		"GetTopActiveCompaniesWithFiltering": ds.GetTopActiveCompaniesWithFiltering(start, end, nil, 10, tenant),
+		"ApproxTopEndpointsByUsage":          ds.ApproxTopEndpointsByUsage(start, end, nil, 10, tenant),
+		"ApproxTopActiveCompanies":           ds.ApproxTopActiveCompanies(start, end, nil, 10, tenant),
+		"GetRetentionAnalytics":              retention,
+		"GetUserPaths":                       ds.GetUserPaths(models.PathRequest{StartDate: start, EndDate: end}, tenant),
+		"GetFeatureAdoption":                 ds.GetFeatureAdoption(start, end, nil, tenant),
+		"GetEventCatalog":                    ds.GetEventCatalog(tenant),
+	}
+	for name, response := range responses {
+		assertNoGlobex(t, ds, name, response)
+	}
+
+	if users := ds.GetUniqueUsersCount(start, end, nil, tenant); users != 2 {
+		t.Errorf("GetUniqueUsersCount = %d, want 2", users)
+	}
+	if users := ds.ApproxUniqueUsersCount(start, end, nil, nil, tenant); users.Estimate != 2 {
+		t.Errorf("ApproxUniqueUsersCount = %d, want 2", users.Estimate)
+	}
+	if metrics := ds.GetMetrics(start, end, nil, nil, tenant); metrics.TotalEvents != 40 {
+		t.Errorf("GetMetrics total events = %d, want 40", metrics.TotalEvents)
+	}
+
+	// Globex's events are the latest of each day, so the range ends early
+	status := ds.GetDataStatus(tenant)
+	if status.TotalEvents != 40 || status.Companies != 1 || status.EventTypes != 1 {
+		t.Errorf("GetDataStatus = %d events, %d companies, %d event types; want 40, 1, 1", status.TotalEvents, status.Companies, status.EventTypes)
+	}
+	if want := time.Date(2025, 1, 20, 10, 0, 0, 0, time.UTC); status.LatestEvent == nil || !status.LatestEvent.Equal(want) {
+		t.Errorf("GetDataStatus latest event = %v, want %v", status.LatestEvent, want)
+	}
+}
+
+func TestTenantCannotSelectOtherCompanies(t *testing.T) {
+	ds := newTenantDataService(t)
+	tenant := ForTenant(acmeID)
+	start, end := "2025-01-01", "2025-01-31"
+	globex := []string{"Globex"}
+
+	if metrics := ds.GetMetrics(start, end, globex, nil, tenant); metrics.TotalEvents != 0 {
+		t.Errorf("GetMetrics for Globex = %d events, want 0", metrics.TotalEvents)
+	}
+	if users := ds.GetMostActiveUsers(start, end, globex, 10, tenant); len(users) != 0 {
+		t.Errorf("GetMostActiveUsers for Globex = %v, want none", users)
+	}
+	search := ds.SearchEvents(models.SearchRequest{Filters: models.SearchFilters{Companies: globex}}, tenant)
+	if search.Pagination.Total != 0 {
+		t.Errorf("SearchEvents for Globex total = %d, want 0", search.Pagination.Total)
+	}
+	assertNoGlobex(t, ds, "GetTimeSeriesData for Globex", ds.GetTimeSeriesData(TimeframeDaily, start, end, globex, nil, tenant))
+
+	if _, found := ds.GetCompanyDetail(globexID, TimeframeDaily, start, end, tenant); found {
+		t.Error("GetCompanyDetail found Globex for the Acme tenant")
+	}
+	detail, found := ds.GetCompanyDetail(acmeID, TimeframeDaily, start, end, tenant)
+	if !found {
+		t.Fatal("GetCompanyDetail did not find Acme for the Acme tenant")
+	}
+	assertNoGlobex(t, ds, "GetCompanyDetail", detail)
+}
+
+func TestTenantUserProfiles(t *testing.T) {
+	ds := newTenantDataService(t)
+	tenant := ForTenant(acmeID)
+	pagination := models.PaginationRequest{Page: 1, PageSize: 100}
+
+	if _, found := ds.GetUserProfile("bob@globex.com", "", "", pagination, tenant); found {
+		t.Error("GetUserProfile found a Globex-only user for the Acme tenant")
+	}
+
+	profile, found := ds.GetUserProfile("carol@shared.com", "", "", pagination, tenant)
+	if !found {
+		t.Fatal("GetUserProfile did not find a user with Acme events")
+	}
+	if profile.TotalEvents != 20 {
+		t.Errorf("GetUserProfile total events = %d, want 20", profile.TotalEvents)
+	}
+	assertNoGlobex(t, ds, "GetUserProfile", profile)
+
+	// Without a tenant the same user spans both companies
+	if profile, _ := ds.GetUserProfile("carol@shared.com", "", "", pagination); profile.TotalEvents != 40 {
+		t.Errorf("unscoped GetUserProfile total events = %d, want 40", profile.TotalEvents)
+	}
+}
+
+func TestTenantSegments(t *testing.T) {
+	ds := newTenantDataService(t)
+	tenant := ForTenant(acmeID)
+
+	segment, err := ds.CreateSegment(models.Segment{
+		Name:  "Shared users",
+		Rules: []models.SegmentRule{{Field: SegmentFieldEmailDomain, Operator: SegmentOpEq, Value: "shared.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scoped := InSegment(segment.ID)
+	assertNoGlobex(t, ds, "segmented SearchEvents", ds.SearchEvents(models.SearchRequest{}, tenant, scoped))
+	assertNoGlobex(t, ds, "segmented GetTimeSeriesData", ds.GetTimeSeriesData(TimeframeDaily, "2025-01-01", "2025-01-31", nil, nil, tenant, scoped))
+	if metrics := ds.GetMetrics("2025-01-01", "2025-01-31", nil, nil, tenant, scoped); metrics.TotalEvents != 20 {
+		t.Errorf("segmented GetMetrics total events = %d, want 20", metrics.TotalEvents)
+	}
+
+	listed, found := ds.GetSegment(segment.ID, tenant)
+	if !found || listed.Users != 1 {
+		t.Errorf("GetSegment users = %d, want 1", listed.Users)
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// timezoneSettings holds the default and per-company query time zones
+type timezoneSettings struct {
+	defaultLocation  *time.Location
+	companyLocations map[string]*time.Location // company name -> zone
+}
+
+// ConfigureTimezones sets the IANA zone used when a request does not pass
+// tz=, optionally overridden per company name
+func (ds *DataService) ConfigureTimezones(defaultZone string, companyZones map[string]string) error {
+	settings := timezoneSettings{
+		defaultLocation:  time.UTC,
+		companyLocations: make(map[string]*time.Location),
+	}
+
+	if defaultZone != "" {
+		loc, err := time.LoadLocation(defaultZone)
+		if err != nil {
+			return fmt.Errorf("invalid default timezone %q: %w", defaultZone, err)
+		}
+		settings.defaultLocation = loc
+	}
+
+	for company, zone := range companyZones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q for company %q: %w", zone, company, err)
+		}
+		settings.companyLocations[company] = loc
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.timezones = settings
+	return nil
+}
+
+// ResolveLocation returns the zone for a query: the explicit tz if given,
+// otherwise the configured zone of the single requested company, otherwise
+// the default zone
+func (ds *DataService) ResolveLocation(tz string, companies []string) (*time.Location, error) {
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", tz)
+		}
+		return loc, nil
+	}
+
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if len(companies) == 1 {
+		if loc, exists := ds.timezones.companyLocations[companies[0]]; exists {
+			return loc, nil
+		}
+	}
+	if ds.timezones.defaultLocation != nil {
+		return ds.timezones.defaultLocation, nil
+	}
+	return time.UTC, nil
+}
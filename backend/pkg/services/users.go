@@ -0,0 +1,172 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+// userProfileTopEndpoints is the number of endpoints in a user profile
+const userProfileTopEndpoints = 10
+
+// GetUserProfile returns a user's companies, activity summary and paginated
+// event timeline within the optional date range. The user may be given by
+// any of their emails; events of every alias are included. ok is false when
+// the user has no events at all, or none in the query's tenant.
+func (ds *DataService) GetUserProfile(user, startDate, endDate string, pagination models.PaginationRequest, opts ...QueryOption) (models.UserProfileResponse, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	q := ds.newQueryOptions(opts)
+
+	identity := ds.identities.resolve(user)
+	postings, exists := ds.index.users[identity.id]
+	if q.tenant != "" {
+		postings = ds.tenantPositions(postings, q.tenant)
+		exists = len(postings) > 0
+	}
+	if !ds.loaded || !exists {
+		return models.UserProfileResponse{}, false
+	}
+
+	// The user's posting list narrowed to the date range
+	lo, hi := 0, len(ds.events)
+	if start, end, ok := parseDateRange(startDate, endDate, q.location); ok {
+		lo, hi = ds.dateBounds(start, end)
+	}
+	events := ds.eventsAt(positionsInRange(postings, lo, hi))
+
+	response := models.UserProfileResponse{
+		UserID:       identity.id,
+		User:         identity.email,
+		Aliases:      []string{},
+		Companies:    []string{},
+		TotalEvents:  len(events),
+		EventsByDay:  []models.DailyCount{},
+		TopEndpoints: []models.EndpointCount{},
+		Timeline:     []models.UsageEvent{},
+	}
+
+	page := pagination.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := pagination.PageSize
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	response.Pagination = models.PaginationInfo{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      len(events),
+		TotalPages: (len(events) + pageSize - 1) / pageSize,
+	}
+
+	if len(events) == 0 {
+		return response, true
+	}
+
+	response.FirstSeen = events[0].CreatedAt
+	response.LastSeen = events[len(events)-1].CreatedAt
+
+	// Aliases, companies, days and endpoints
+	b := newBucketer(TimeframeDaily, q)
+	aliasSet := make(map[string]bool)
+	companySet := make(map[string]bool)
+	dayCounts := make(map[string]int)
+	endpointCounts := make(map[string]int)
+	for _, event := range events {
+		aliasSet[event.User] = true
+		companySet[companyNameFor(ds.companies, event.CompanyID)] = true
+		dayCounts[b.key(event.CreatedAt)]++
+		if event.Endpoint != "" {
+			endpointCounts[event.Endpoint]++
+		}
+	}
+
+	for alias := range aliasSet {
+		response.Aliases = append(response.Aliases, alias)
+	}
+	sort.Strings(response.Aliases)
+
+	for companyName := range companySet {
+		response.Companies = append(response.Companies, companyName)
+	}
+	sort.Strings(response.Companies)
+
+	for _, day := range b.series(response.FirstSeen, response.LastSeen.Add(time.Nanosecond)) {
+		response.EventsByDay = append(response.EventsByDay, models.DailyCount{
+			Date:  day,
+			Count: dayCounts[day],
+		})
+	}
+
+	for endpoint, count := range endpointCounts {
+		response.TopEndpoints = append(response.TopEndpoints, models.EndpointCount{
+			Endpoint: endpoint,
+			Count:    count,
+		})
+	}
+	sort.Slice(response.TopEndpoints, func(i, j int) bool {
+		if response.TopEndpoints[i].Count != response.TopEndpoints[j].Count {
+			return response.TopEndpoints[i].Count > response.TopEndpoints[j].Count
+		}
+		return response.TopEndpoints[i].Endpoint < response.TopEndpoints[j].Endpoint
+	})
+	if len(response.TopEndpoints) > userProfileTopEndpoints {
+		response.TopEndpoints = response.TopEndpoints[:userProfileTopEndpoints]
+	}
+
+	response.Sessions = summarizeSessions(events)
+
+	// Timeline pages run newest first. Pages past the last are empty; the
+	// page is checked before it is multiplied so a huge page cannot overflow.
+	if page <= response.Pagination.TotalPages {
+		from := len(events) - (page-1)*pageSize
+		to := max(from-pageSize, 0)
+		for i := from - 1; i >= to; i-- {
+			event := events[i]
+			event.CompanyName = companyNameFor(ds.companies, event.CompanyID)
+			response.Timeline = append(response.Timeline, event)
+		}
+	}
+
+	return response, true
+}
+
+// summarizeSessions splits time-ordered events into sessions at gaps longer
+// than sessionGap and summarizes their durations and sizes
+func summarizeSessions(events []models.UsageEvent) models.SessionSummary {
+	var summary models.SessionSummary
+	if len(events) == 0 {
+		return summary
+	}
+
+	var totalDuration time.Duration
+	sessionStart := events[0].CreatedAt
+	previous := events[0].CreatedAt
+
+	closeSession := func() {
+		duration := previous.Sub(sessionStart)
+		totalDuration += duration
+		summary.Count++
+		if duration.Seconds() > summary.LongestDurationSeconds {
+			summary.LongestDurationSeconds = duration.Seconds()
+		}
+		summary.LastSessionStart = sessionStart
+	}
+
+	for _, event := range events[1:] {
+		if event.CreatedAt.Sub(previous) > sessionGap {
+			closeSession()
+			sessionStart = event.CreatedAt
+		}
+		previous = event.CreatedAt
+	}
+	closeSession()
+
+	summary.AvgDurationSeconds = totalDuration.Seconds() / float64(summary.Count)
+	summary.AvgEventsPerSession = float64(len(events)) / float64(summary.Count)
+	return summary
+}
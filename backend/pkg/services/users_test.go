@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"analytics-dashboard/pkg/models"
+)
+
+func TestUserProfileTimelinePages(t *testing.T) {
+	ds, err := NewDataService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	var events []models.UsageEvent
+	for i := 0; i < 25; i++ {
+		events = append(events, models.UsageEvent{
+			ID:        fmt.Sprintf("event-%d", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			CompanyID: "cmp-test",
+			Type:      "Action",
+			Content:   "User active CMMS - Test dana@test.com /home",
+			User:      "dana@test.com",
+		})
+	}
+	if err := ds.IngestEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		page  int
+		count int
+		first string
+	}{
+		{1, 10, "event-24"},
+		{3, 5, "event-4"},
+		{4, 0, ""},
+		{math.MaxInt, 0, ""},
+		{math.MaxInt/10 + 1, 0, ""},
+	}
+	for _, tc := range cases {
+		profile, found := ds.GetUserProfile("dana@test.com", "", "", models.PaginationRequest{Page: tc.page, PageSize: 10})
+		if !found {
+			t.Fatalf("page %d: user not found", tc.page)
+		}
+		if len(profile.Timeline) != tc.count {
+			t.Errorf("page %d: %d timeline events, want %d", tc.page, len(profile.Timeline), tc.count)
+			continue
+		}
+		if tc.count > 0 && profile.Timeline[0].ID != tc.first {
+			t.Errorf("page %d starts with %s, want %s", tc.page, profile.Timeline[0].ID, tc.first)
+		}
+		if profile.Pagination.TotalPages != 3 {
+			t.Errorf("page %d: %d total pages, want 3", tc.page, profile.Pagination.TotalPages)
+		}
+	}
+}
@@ -195,7 +195,7 @@ const Dashboard: React.FC = () => {
           <Card>
             <CardContent className="p-4">
               <div className="text-2xl sm:text-3xl font-bold">
-                {Math.round(trendsData.reduce((sum, item) => sum + item.value, 0) / trendsData.length)}
+                {Math.round(trendsData.reduce((sum, item) => sum + (item.value ?? 0), 0) / trendsData.length)}
               </div>
               <div className="text-sm sm:text-base text-muted-foreground">Average per Period</div>
             </CardContent>
@@ -203,7 +203,7 @@ const Dashboard: React.FC = () => {
           <Card>
             <CardContent className="p-4">
               <div className="text-2xl sm:text-3xl font-bold">
-                {Math.max(...trendsData.map(item => item.value))}
+                {Math.max(...trendsData.map(item => item.value ?? 0))}
               </div>
               <div className="text-sm sm:text-base text-muted-foreground">Peak Events</div>
             </CardContent>
@@ -18,13 +18,19 @@ export interface MetricsResponse {
 
 export interface TimeSeriesData {
   timestamp: string;
-  value: number;
+  series?: string; // Set when the series is split by a dimension
+  value: number | null; // Null for a measure over a bucket without non-null values
+  nullCount?: number; // Events with a null value; set for measures
   eventType: string;
 }
 
 export interface TimeSeriesResponse {
   data: TimeSeriesData[];
   timeframe: string;
+  timezone?: string;
+  splitBy?: 'company' | 'user' | 'endpoint' | 'type' | 'attribute';
+  series?: string[];
+  measure?: 'sum' | 'avg' | 'min' | 'max' | 'p50' | 'p90' | 'p99';
   totalPoints: number;
 }
 
@@ -98,6 +104,7 @@ export interface EndpointActivity {
 }
 
 export interface UserActivity {
+  userId: string;
   user: string;
   eventCount: number;
   companies: number;